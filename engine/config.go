@@ -1,6 +1,9 @@
 package engine
 
-import "time"
+import (
+	"io"
+	"time"
+)
 
 type EngineConfig struct {
 	Proxy ProxyConfig
@@ -15,7 +18,9 @@ type ProxyConfig struct {
 }
 
 type ContainerConfig struct {
-	Name string
+	Name    string
+	AppName string
+	Role    string
 
 	// Internal
 	Hostname   string
@@ -33,8 +38,9 @@ type ContainerConfig struct {
 	NetContainer string
 	HostIP       string
 	HostPort     string
-	Memory       int64 // in bytes
-	DiskQuota    int64 // in bytes
+	Ports        []PortBinding // in addition to Port
+	Memory       int64         // in bytes
+	DiskQuota    int64         // in bytes
 
 	// Healthcheck
 	Test        []string
@@ -43,14 +49,55 @@ type ContainerConfig struct {
 	StartPeriod time.Duration
 	Retries     int
 
+	// Logging
+	LogSink LogSink // default: prefixed text written to Start logs
+
 	// Control
 	Exit  <-chan struct{}  // default: inherit from engine
 	Check <-chan time.Time // default: 1 second intervals
 }
 
+type BuildConfig struct {
+	Context     io.Reader // tar archive, overrides ContextDir
+	ContextDir  string    // .dockerignore is honored
+	Dockerfile  string    // default: Dockerfile
+	Tags        []string
+	BuildArgs   map[string]string
+	Labels      map[string]string
+	Target      string
+	NoCache     bool
+	PullParent  bool
+	NetworkMode string
+}
+
+type PortBinding struct {
+	Protocol      string // default: tcp
+	ContainerPort string // port or range, e.g. 8000-8010
+	HostIP        string // default: all interfaces
+	HostPort      string // default: assigned by engine
+}
+
+// GCConfig configures the removal of containers and untagged images left by other sessions.
+// Images do not record the process that created them, so they are only removed when older than MaxAge.
+type GCConfig struct {
+	MaxAge time.Duration // default: only remove containers from dead sessions
+	DryRun bool          // list orphans without removing them
+}
+
+type ExecConfig struct {
+	Cmd        []string
+	Env        []string  // appended to container env
+	User       string    // default: container user
+	WorkingDir string    // default: container working dir
+	Stdin      io.Reader // optional
+	Stdout     io.Writer // default: discard
+	Stderr     io.Writer // default: discard
+}
+
 type RegistryCreds struct {
 	Username      string `json:"username"`
 	Password      string `json:"password"`
 	Email         string `json:"email"`
 	ServerAddress string `json:"serveraddress"`
+	IdentityToken string `json:"identitytoken,omitempty"`
 }
@@ -2,7 +2,10 @@ package archive
 
 import (
 	"io"
+	"os"
+	"path/filepath"
 
+	"github.com/docker/docker/builder/dockerignore"
 	"github.com/docker/docker/pkg/archive"
 )
 
@@ -12,6 +15,30 @@ func Tar(dir string, files []string) (io.ReadCloser, error) {
 	})
 }
 
+func TarContext(dir, dockerfile string) (io.ReadCloser, error) {
+	excludes, err := readDockerignore(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(excludes) > 0 {
+		excludes = append(excludes, "!"+filepath.ToSlash(filepath.Clean(dockerfile)), "!.dockerignore")
+	}
+	return archive.TarWithOptions(dir, &archive.TarOptions{
+		ExcludePatterns: excludes,
+	})
+}
+
+func readDockerignore(dir string) ([]string, error) {
+	f, err := os.Open(filepath.Join(dir, ".dockerignore"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return dockerignore.ReadAll(f)
+}
+
 func Copy(src, dst string) error {
 	return archive.CopyResource(src, dst, false)
 }
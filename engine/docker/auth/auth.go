@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker-credential-helpers/client"
+	"github.com/docker/docker-credential-helpers/credentials"
+	"github.com/docker/docker/pkg/homedir"
+
+	eng "github.com/buildpack/forge/engine"
+)
+
+const DockerHubServer = "https://index.docker.io/v1/"
+
+type Config struct {
+	Auths       map[string]Entry  `json:"auths,omitempty"`
+	CredsStore  string            `json:"credsStore,omitempty"`
+	CredHelpers map[string]string `json:"credHelpers,omitempty"`
+}
+
+type Entry struct {
+	Auth          string `json:"auth,omitempty"` // base64 of username:password
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	Email         string `json:"email,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+}
+
+func Dir() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir
+	}
+	return filepath.Join(homedir.Get(), ".docker")
+}
+
+func Load(dir string) (*Config, error) {
+	config := &Config{}
+	file, err := os.Open(filepath.Join(dir, "config.json"))
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if err := json.NewDecoder(file).Decode(config); err != nil {
+		return nil, fmt.Errorf("invalid docker config %s: %s", file.Name(), err)
+	}
+	return config, nil
+}
+
+// Creds returns the credentials for the registry hosting ref,
+// or empty credentials if none are configured.
+func (c *Config) Creds(ref string) (eng.RegistryCreds, error) {
+	return c.RegistryCreds(Registry(ref))
+}
+
+func (c *Config) RegistryCreds(server string) (eng.RegistryCreds, error) {
+	if helper := c.helper(server); helper != "" {
+		creds, err := helperCreds(helper, server)
+		if err != nil || creds != (eng.RegistryCreds{}) {
+			return creds, err
+		}
+	}
+	for key, entry := range c.Auths {
+		if hostname(key) == hostname(server) {
+			return entry.creds(key)
+		}
+	}
+	return eng.RegistryCreds{}, nil
+}
+
+// AllCreds returns the credentials for every configured registry, keyed by server address.
+func (c *Config) AllCreds() (map[string]eng.RegistryCreds, error) {
+	servers := map[string]bool{}
+	for server := range c.Auths {
+		servers[server] = true
+	}
+	for server := range c.CredHelpers {
+		servers[server] = true
+	}
+	if c.CredsStore != "" {
+		stored, err := client.List(program(c.CredsStore))
+		if err != nil {
+			return nil, err
+		}
+		for server := range stored {
+			servers[server] = true
+		}
+	}
+	all := map[string]eng.RegistryCreds{}
+	for server := range servers {
+		creds, err := c.RegistryCreds(server)
+		if err != nil {
+			return nil, err
+		}
+		if creds != (eng.RegistryCreds{}) {
+			all[server] = creds
+		}
+	}
+	return all, nil
+}
+
+func (c *Config) helper(server string) string {
+	if helper, ok := c.CredHelpers[hostname(server)]; ok {
+		return helper
+	}
+	return c.CredsStore
+}
+
+func (e Entry) creds(server string) (eng.RegistryCreds, error) {
+	creds := eng.RegistryCreds{
+		Username:      e.Username,
+		Password:      e.Password,
+		Email:         e.Email,
+		IdentityToken: e.IdentityToken,
+		ServerAddress: server,
+	}
+	if e.Auth == "" {
+		return creds, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(e.Auth)
+	if err != nil {
+		return eng.RegistryCreds{}, fmt.Errorf("invalid auth for %s: %s", server, err)
+	}
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if len(parts) != 2 {
+		return eng.RegistryCreds{}, fmt.Errorf("invalid auth for %s", server)
+	}
+	creds.Username, creds.Password = parts[0], strings.Trim(parts[1], "\x00")
+	return creds, nil
+}
+
+func helperCreds(helper, server string) (eng.RegistryCreds, error) {
+	creds, err := client.Get(program(helper), server)
+	if credentials.IsErrCredentialsNotFound(err) {
+		return eng.RegistryCreds{}, nil
+	}
+	if err != nil {
+		return eng.RegistryCreds{}, fmt.Errorf("credential helper %s: %s", helper, err)
+	}
+	if creds.Username == "<token>" {
+		return eng.RegistryCreds{IdentityToken: creds.Secret, ServerAddress: server}, nil
+	}
+	return eng.RegistryCreds{
+		Username:      creds.Username,
+		Password:      creds.Secret,
+		ServerAddress: server,
+	}, nil
+}
+
+func program(helper string) client.ProgramFunc {
+	return client.NewShellProgramFunc("docker-credential-" + helper)
+}
+
+// Registry returns the server address of the registry hosting ref.
+func Registry(ref string) string {
+	i := strings.IndexRune(ref, '/')
+	if i == -1 {
+		return DockerHubServer
+	}
+	domain := ref[:i]
+	if !strings.ContainsAny(domain, ".:") && domain != "localhost" ||
+		domain == "docker.io" || domain == "index.docker.io" {
+		return DockerHubServer
+	}
+	return domain
+}
+
+func hostname(server string) string {
+	server = strings.TrimPrefix(server, "http://")
+	server = strings.TrimPrefix(server, "https://")
+	return strings.SplitN(server, "/", 2)[0]
+}
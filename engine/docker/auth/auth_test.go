@@ -0,0 +1,163 @@
+package auth_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	eng "github.com/buildpack/forge/engine"
+	. "github.com/buildpack/forge/engine/docker/auth"
+)
+
+const helperScript = `#!/bin/sh
+read server
+case "$1" in
+get)
+	case "$server" in
+	some-registry.com) echo '{"ServerURL":"some-registry.com","Username":"some-helper-user","Secret":"some-helper-secret"}' ;;
+	some-token-registry.com) echo '{"ServerURL":"some-token-registry.com","Username":"<token>","Secret":"some-token"}' ;;
+	*) echo "credentials not found in native keychain"; exit 1 ;;
+	esac ;;
+list) echo '{"some-registry.com":"some-helper-user"}' ;;
+esac
+`
+
+var _ = Describe("Config", func() {
+	var (
+		tmpDir string
+		path   string
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "forge.auth.test")
+		Expect(err).NotTo(HaveOccurred())
+		helper := filepath.Join(tmpDir, "docker-credential-some-helper")
+		Expect(ioutil.WriteFile(helper, []byte(helperScript), 0755)).To(Succeed())
+		path = os.Getenv("PATH")
+		Expect(os.Setenv("PATH", tmpDir+string(os.PathListSeparator)+path)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.Setenv("PATH", path)).To(Succeed())
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	Describe(".Load", func() {
+		It("should load the docker config", func() {
+			Expect(ioutil.WriteFile(filepath.Join(tmpDir, "config.json"), []byte(`{
+				"auths": {"some-registry.com": {"auth": "c29tZS11c2VyOnNvbWUtcGFzc3dvcmQ="}},
+				"credsStore": "some-store",
+				"credHelpers": {"some-other-registry.com": "some-helper"}
+			}`), 0666)).To(Succeed())
+			config, err := Load(tmpDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config).To(Equal(&Config{
+				Auths:       map[string]Entry{"some-registry.com": {Auth: "c29tZS11c2VyOnNvbWUtcGFzc3dvcmQ="}},
+				CredsStore:  "some-store",
+				CredHelpers: map[string]string{"some-other-registry.com": "some-helper"},
+			}))
+		})
+
+		It("should return an empty config when the file is missing", func() {
+			Expect(Load(tmpDir)).To(Equal(&Config{}))
+		})
+
+		It("should return an error when the file is invalid", func() {
+			Expect(ioutil.WriteFile(filepath.Join(tmpDir, "config.json"), []byte("some-bad-json"), 0666)).To(Succeed())
+			_, err := Load(tmpDir)
+			Expect(err).To(MatchError(HavePrefix("invalid docker config")))
+		})
+	})
+
+	Describe("#Creds", func() {
+		It("should decode credentials from auths", func() {
+			config := &Config{Auths: map[string]Entry{
+				"https://some-registry.com/v2/": {Auth: "c29tZS11c2VyOnNvbWUtcGFzc3dvcmQ="},
+				DockerHubServer:                 {Username: "some-hub-user", Password: "some-hub-password"},
+			}}
+			Expect(config.Creds("some-registry.com/some-image:some-tag")).To(Equal(eng.RegistryCreds{
+				Username:      "some-user",
+				Password:      "some-password",
+				ServerAddress: "https://some-registry.com/v2/",
+			}))
+			Expect(config.Creds("some-org/some-image")).To(Equal(eng.RegistryCreds{
+				Username:      "some-hub-user",
+				Password:      "some-hub-password",
+				ServerAddress: DockerHubServer,
+			}))
+			Expect(config.Creds("localhost:5000/some-image")).To(BeZero())
+		})
+
+		It("should retrieve credentials from credential helpers", func() {
+			config := &Config{
+				Auths:       map[string]Entry{"some-other-registry.com": {Username: "some-user"}},
+				CredHelpers: map[string]string{"some-registry.com": "some-helper", "some-token-registry.com": "some-helper"},
+			}
+			Expect(config.Creds("some-registry.com/some-image")).To(Equal(eng.RegistryCreds{
+				Username:      "some-helper-user",
+				Password:      "some-helper-secret",
+				ServerAddress: "some-registry.com",
+			}))
+			Expect(config.Creds("some-token-registry.com/some-image")).To(Equal(eng.RegistryCreds{
+				IdentityToken: "some-token",
+				ServerAddress: "some-token-registry.com",
+			}))
+			Expect(config.Creds("some-other-registry.com/some-image")).To(Equal(eng.RegistryCreds{
+				Username:      "some-user",
+				ServerAddress: "some-other-registry.com",
+			}))
+		})
+
+		It("should fall back to auths when the credential store has no credentials", func() {
+			config := &Config{
+				Auths:      map[string]Entry{"some-other-registry.com": {Username: "some-user"}},
+				CredsStore: "some-helper",
+			}
+			Expect(config.Creds("some-other-registry.com/some-image")).To(Equal(eng.RegistryCreds{
+				Username:      "some-user",
+				ServerAddress: "some-other-registry.com",
+			}))
+		})
+
+		It("should return an error when the credential helper is missing", func() {
+			config := &Config{CredsStore: "some-missing-helper"}
+			_, err := config.Creds("some-registry.com/some-image")
+			Expect(err).To(MatchError(HavePrefix("credential helper some-missing-helper")))
+		})
+	})
+
+	Describe("#AllCreds", func() {
+		It("should return credentials for every configured registry", func() {
+			config := &Config{
+				Auths:      map[string]Entry{"some-other-registry.com": {Username: "some-user"}},
+				CredsStore: "some-helper",
+			}
+			Expect(config.AllCreds()).To(Equal(map[string]eng.RegistryCreds{
+				"some-registry.com": {
+					Username:      "some-helper-user",
+					Password:      "some-helper-secret",
+					ServerAddress: "some-registry.com",
+				},
+				"some-other-registry.com": {
+					Username:      "some-user",
+					ServerAddress: "some-other-registry.com",
+				},
+			}))
+		})
+	})
+
+	Describe(".Registry", func() {
+		It("should return the registry server for an image reference", func() {
+			Expect(Registry("some-image")).To(Equal(DockerHubServer))
+			Expect(Registry("some-org/some-image:some-tag")).To(Equal(DockerHubServer))
+			Expect(Registry("docker.io/some-org/some-image")).To(Equal(DockerHubServer))
+			Expect(Registry("some-registry.com/some-image")).To(Equal("some-registry.com"))
+			Expect(Registry("localhost/some-image")).To(Equal("localhost"))
+			Expect(Registry("some-host:5000/some-image")).To(Equal("some-host:5000"))
+		})
+	})
+})
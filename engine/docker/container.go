@@ -10,6 +10,7 @@ import (
 	"io"
 	"io/ioutil"
 	gopath "path"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,11 +25,15 @@ import (
 )
 
 type container struct {
-	exit   <-chan struct{}
-	check  <-chan time.Time
-	docker *docker.Client
-	id     string
-	config *cont.Config
+	exit    <-chan struct{}
+	check   <-chan time.Time
+	docker  *docker.Client
+	engine  *engine
+	id      string
+	config  *cont.Config
+	role    string
+	appName string
+	logSink eng.LogSink
 }
 
 func (e *engine) NewContainer(config *eng.ContainerConfig) (eng.Container, error) {
@@ -45,6 +50,7 @@ func (e *engine) NewContainer(config *eng.ContainerConfig) (eng.Container, error
 		Env:        append(e.proxyEnv(config), config.Env...),
 		Entrypoint: strslice.StrSlice(config.Entrypoint),
 		Cmd:        strslice.StrSlice(config.Cmd),
+		Labels:     e.containerLabels(config.Role, config.AppName),
 	}
 	hostConfig := &cont.HostConfig{
 		Binds: config.Binds,
@@ -65,18 +71,19 @@ func (e *engine) NewContainer(config *eng.ContainerConfig) (eng.Container, error
 	if config.NetContainer != "" {
 		contConfig.Hostname = ""
 		hostConfig.NetworkMode = cont.NetworkMode("container:" + config.NetContainer)
-	} else if config.Port != "" {
-		port, err := nat.NewPort("tcp", config.Port)
+	} else {
+		bindings := config.Ports
+		if config.Port != "" {
+			bindings = append([]eng.PortBinding{{
+				ContainerPort: config.Port,
+				HostIP:        config.HostIP,
+				HostPort:      config.HostPort,
+			}}, bindings...)
+		}
+		contConfig.ExposedPorts, hostConfig.PortBindings, err = portMap(bindings)
 		if err != nil {
 			return nil, err
 		}
-		contConfig.ExposedPorts = nat.PortSet{port: struct{}{}}
-		hostConfig.PortBindings = nat.PortMap{
-			port: {{
-				HostIP:   config.HostIP,
-				HostPort: config.HostPort,
-			}},
-		}
 	}
 	check := config.Check
 	if check == nil {
@@ -91,7 +98,50 @@ func (e *engine) NewContainer(config *eng.ContainerConfig) (eng.Container, error
 	if err != nil {
 		return nil, err
 	}
-	return &container{exit, check, e.docker, response.ID, contConfig}, nil
+	return &container{exit, check, e.docker, e, response.ID, contConfig, config.Role, config.AppName, config.LogSink}, nil
+}
+
+func portMap(bindings []eng.PortBinding) (nat.PortSet, nat.PortMap, error) {
+	if len(bindings) == 0 {
+		return nil, nil, nil
+	}
+	exposed := nat.PortSet{}
+	portMap := nat.PortMap{}
+	for _, b := range bindings {
+		proto := b.Protocol
+		if proto == "" {
+			proto = "tcp"
+		}
+		start, end, err := nat.ParsePortRange(b.ContainerPort)
+		if err != nil {
+			return nil, nil, err
+		}
+		var hostStart, hostEnd uint64
+		if b.HostPort != "" {
+			if hostStart, hostEnd, err = nat.ParsePortRange(b.HostPort); err != nil {
+				return nil, nil, err
+			}
+			if end != start && hostEnd-hostStart != end-start {
+				return nil, nil, fmt.Errorf("invalid port range: %s:%s", b.HostPort, b.ContainerPort)
+			}
+		}
+		for i := uint64(0); i <= end-start; i++ {
+			port, err := nat.NewPort(proto, strconv.FormatUint(start+i, 10))
+			if err != nil {
+				return nil, nil, err
+			}
+			hostPort := b.HostPort
+			if hostPort != "" && end != start {
+				hostPort = strconv.FormatUint(hostStart+i, 10)
+			}
+			exposed[port] = struct{}{}
+			portMap[port] = append(portMap[port], nat.PortBinding{
+				HostIP:   b.HostIP,
+				HostPort: hostPort,
+			})
+		}
+	}
+	return exposed, portMap, nil
 }
 
 func (c *container) ID() string {
@@ -121,24 +171,56 @@ func (c *container) Background() error {
 	return c.docker.ContainerStart(ctx, c.id, types.ContainerStartOptions{})
 }
 
+func (c *container) Stop(timeout time.Duration) error {
+	return c.StopContext(context.Background(), timeout)
+}
+
+func (c *container) StopContext(ctx context.Context, timeout time.Duration) error {
+	return c.docker.ContainerStop(ctx, c.id, &timeout)
+}
+
+func (c *container) Kill(signal string) error {
+	return c.KillContext(context.Background(), signal)
+}
+
+func (c *container) KillContext(ctx context.Context, signal string) error {
+	return c.docker.ContainerKill(ctx, c.id, signal)
+}
+
+func (c *container) Pause() error {
+	return c.PauseContext(context.Background())
+}
+
+func (c *container) PauseContext(ctx context.Context) error {
+	return c.docker.ContainerPause(ctx, c.id)
+}
+
+func (c *container) Unpause() error {
+	return c.UnpauseContext(context.Background())
+}
+
+func (c *container) UnpauseContext(ctx context.Context) error {
+	return c.docker.ContainerUnpause(ctx, c.id)
+}
+
 func (c *container) Start(logPrefix string, logs io.Writer, restart <-chan time.Time) (status int64, err error) {
+	return c.StartContext(context.Background(), logPrefix, logs, restart)
+}
+
+func (c *container) StartContext(ctx context.Context, logPrefix string, logs io.Writer, restart <-chan time.Time) (status int64, err error) {
 	defer func() {
 		if isErrCanceled(err) {
 			status, err = 128, nil
 		}
 	}()
-	done := make(chan struct{})
-	defer close(done)
-	ctx, cancel := context.WithCancel(context.Background())
-	go func() {
-		select {
-		case <-done:
-			cancel()
-		case <-c.exit:
-			cancel()
-		}
-	}()
-	logQueue := copyStreams(logs, logPrefix)
+	ctx, cancel := withExit(ctx, c.exit)
+	defer cancel()
+	var logQueue chan<- io.Reader
+	if c.logSink != nil {
+		logQueue = c.copyRecords(c.logSink)
+	} else {
+		logQueue = copyStreams(logs, logPrefix)
+	}
 	defer close(logQueue)
 
 	if err := c.docker.ContainerStart(ctx, c.id, types.ContainerStartOptions{}); err != nil {
@@ -202,7 +284,7 @@ func (c *container) restart(ctx context.Context, contLogs io.ReadCloser, logQueu
 				continue
 			}
 			logQueue <- contLogs
-		case <-c.exit:
+		case <-ctx.Done():
 			defer contLogs.Close()
 			return 128
 		}
@@ -210,7 +292,21 @@ func (c *container) restart(ctx context.Context, contLogs io.ReadCloser, logQueu
 }
 
 func isErrCanceled(err error) bool {
-	return err == context.Canceled || (err != nil && strings.HasSuffix(err.Error(), "canceled"))
+	return err == context.Canceled || err == context.DeadlineExceeded ||
+		(err != nil && (strings.HasSuffix(err.Error(), "canceled") ||
+			strings.HasSuffix(err.Error(), "deadline exceeded")))
+}
+
+func withExit(ctx context.Context, exit <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-exit:
+			cancel()
+		}
+	}()
+	return ctx, cancel
 }
 
 func copyStreams(dst io.Writer, prefix string) chan<- io.Reader {
@@ -226,32 +322,152 @@ func copyStreams(dst io.Writer, prefix string) chan<- io.Reader {
 					break
 				}
 				// TODO: bold STDERR
-				if _, err := io.CopyN(dst, src, int64(binary.BigEndian.Uint32(header[4:]))); err != nil {
+				if _, err := io.CopyN(dst, src, frameSize(header)); err != nil {
+					break
+				}
+			}
+		}
+	}()
+	return srcs
+}
+
+// copyRecords sends each line of the streams to sink. Docker splits long lines
+// into several frames, so frames that do not end a line are joined with the next.
+func (c *container) copyRecords(sink eng.LogSink) chan<- io.Reader {
+	srcs := make(chan io.Reader)
+	go func() {
+		header := make([]byte, 8)
+		for src := range srcs {
+			partial := map[byte]*eng.LogRecord{}
+			for {
+				if _, err := io.ReadFull(src, header); err != nil {
+					break
+				}
+				frame, eol, err := readFrame(src, frameSize(header))
+				if err != nil {
+					break
+				}
+				t, message := splitTimestamp(string(frame))
+				record := partial[header[0]]
+				if record == nil {
+					record = c.logRecord(header[0], t)
+				}
+				record.Message += message
+				if !eol && len(record.Message) < maxLogLine {
+					partial[header[0]] = record
+					continue
+				}
+				delete(partial, header[0])
+				if err := sink.Log(record); err != nil {
 					break
 				}
 			}
+			for _, record := range partial {
+				sink.Log(record)
+			}
 		}
 	}()
 	return srcs
 }
 
+func (c *container) logRecord(stream byte, t time.Time) *eng.LogRecord {
+	record := &eng.LogRecord{
+		Stream:      eng.Stdout,
+		Time:        t,
+		ContainerID: c.id,
+		AppName:     c.appName,
+	}
+	if stream == streamStderr {
+		record.Stream = eng.Stderr
+	}
+	return record
+}
+
+// maxLogLine bounds the memory used for a log line. Longer lines are split into several records.
+const maxLogLine = 1024 * 1024
+
+// readFrame reads a frame of size bytes and returns at most maxLogLine of them,
+// without the newline, and whether the frame ends a line.
+func readFrame(src io.Reader, size int64) (frame []byte, eol bool, err error) {
+	n := size
+	if n > maxLogLine {
+		n = maxLogLine
+	}
+	frame = make([]byte, n)
+	if _, err := io.ReadFull(src, frame); err != nil {
+		return nil, false, err
+	}
+	if rest := size - n; rest > 0 {
+		if _, err := io.CopyN(ioutil.Discard, src, rest-1); err != nil {
+			return nil, false, err
+		}
+		last := make([]byte, 1)
+		if _, err := io.ReadFull(src, last); err != nil {
+			return nil, false, err
+		}
+		return frame, last[0] == '\n', nil
+	}
+	if n > 0 && frame[n-1] == '\n' {
+		return frame[:n-1], true, nil
+	}
+	return frame, false, nil
+}
+
+// splitTimestamp removes the timestamp that Docker adds to the start of each frame.
+func splitTimestamp(frame string) (time.Time, string) {
+	if i := strings.IndexByte(frame, ' '); i > 0 {
+		if t, err := time.Parse(time.RFC3339Nano, frame[:i]); err == nil {
+			return t, frame[i+1:]
+		}
+	}
+	return time.Time{}, frame
+}
+
+const (
+	streamStdout = 1
+	streamStderr = 2
+)
+
+func frameSize(header []byte) int64 {
+	return int64(binary.BigEndian.Uint32(header[4:]))
+}
+
+func demuxStreams(stdout, stderr io.Writer, src io.Reader) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(src, header); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var dst io.Writer
+		switch header[0] {
+		case streamStdout:
+			dst = stdout
+		case streamStderr:
+			dst = stderr
+		}
+		if dst == nil {
+			dst = ioutil.Discard
+		}
+		if _, err := io.CopyN(dst, src, frameSize(header)); err != nil {
+			return err
+		}
+	}
+}
+
 func (c *container) Shell(tty eng.TTY, shell ...string) (err error) {
+	return c.ShellContext(context.Background(), tty, shell...)
+}
+
+func (c *container) ShellContext(ctx context.Context, tty eng.TTY, shell ...string) (err error) {
 	defer func() {
 		if isErrCanceled(err) {
 			err = nil
 		}
 	}()
-	done := make(chan struct{})
-	defer close(done)
-	ctx, cancel := context.WithCancel(context.Background())
-	go func() {
-		select {
-		case <-done:
-			cancel()
-		case <-c.exit:
-			cancel()
-		}
-	}()
+	ctx, cancel := withExit(ctx, c.exit)
+	defer cancel()
 
 	config := types.ExecConfig{
 		User:         c.config.User,
@@ -277,14 +493,77 @@ func (c *container) Shell(tty eng.TTY, shell ...string) (err error) {
 	})
 }
 
+func (c *container) Exec(config *eng.ExecConfig) (status int64, err error) {
+	return c.ExecContext(context.Background(), config)
+}
+
+func (c *container) ExecContext(ctx context.Context, config *eng.ExecConfig) (status int64, err error) {
+	defer func() {
+		if isErrCanceled(err) {
+			status, err = 128, nil
+		}
+	}()
+	ctx, cancel := withExit(ctx, c.exit)
+	defer cancel()
+
+	user := config.User
+	if user == "" {
+		user = c.config.User
+	}
+	workingDir := config.WorkingDir
+	if workingDir == "" {
+		workingDir = c.config.WorkingDir
+	}
+	execConfig := types.ExecConfig{
+		User:         user,
+		AttachStdin:  config.Stdin != nil,
+		AttachStderr: true,
+		AttachStdout: true,
+		Env:          append(append([]string(nil), c.config.Env...), config.Env...),
+		WorkingDir:   workingDir,
+		Cmd:          config.Cmd,
+	}
+	idResp, err := c.docker.ContainerExecCreate(ctx, c.id, execConfig)
+	if err != nil {
+		return 0, err
+	}
+
+	attachResp, err := c.docker.ContainerExecAttach(ctx, idResp.ID, types.ExecStartCheck{})
+	if err != nil {
+		return 0, err
+	}
+	defer attachResp.Close()
+
+	if config.Stdin != nil {
+		go func() {
+			defer attachResp.CloseWrite()
+			io.Copy(attachResp.Conn, config.Stdin)
+		}()
+	}
+	if err := demuxStreams(config.Stdout, config.Stderr, attachResp.Reader); err != nil {
+		return 0, err
+	}
+
+	inspect, err := c.docker.ContainerExecInspect(ctx, idResp.ID)
+	if err != nil {
+		return 0, err
+	}
+	return int64(inspect.ExitCode), nil
+}
+
 func (c *container) HealthCheck() <-chan string {
+	return c.HealthCheckContext(context.Background())
+}
+
+func (c *container) HealthCheckContext(ctx context.Context) <-chan string {
 	status := make(chan string)
 	go func() {
-		ctx := context.Background()
 		for {
 			select {
 			case <-c.exit:
 				return
+			case <-ctx.Done():
+				return
 			case <-c.check:
 				contJSON, err := c.docker.ContainerInspect(ctx, c.id)
 				if err != nil || contJSON.State == nil || contJSON.State.Health == nil {
@@ -299,17 +578,41 @@ func (c *container) HealthCheck() <-chan string {
 }
 
 func (c *container) Commit(ref string) (imageID string, err error) {
-	ctx := context.Background()
+	return c.CommitContext(context.Background(), ref)
+}
+
+func (c *container) CommitContext(ctx context.Context, ref string) (imageID string, err error) {
+	base, _, err := c.docker.ImageInspectWithRaw(ctx, c.config.Image)
+	if err != nil {
+		return "", err
+	}
+	config := *c.config
+	config.Labels = map[string]string{}
+	if base.Config != nil {
+		for k, v := range base.Config.Labels {
+			config.Labels[k] = v
+		}
+	}
+	for k, v := range c.engine.imageLabels(c.role, c.appName) {
+		config.Labels[k] = v
+	}
+	// the daemon adds missing labels from the container, but images
+	// must not record the host or process that committed them
+	config.Labels[eng.LabelHost] = ""
+	config.Labels[eng.LabelPID] = ""
 	response, err := c.docker.ContainerCommit(ctx, c.id, types.ContainerCommitOptions{
 		Reference: ref,
 		Pause:     true,
-		Config:    c.config,
+		Config:    &config,
 	})
 	return response.ID, err
 }
 
 func (c *container) UploadTarTo(tar io.Reader, path string) error {
-	ctx := context.Background()
+	return c.UploadTarToContext(context.Background(), tar, path)
+}
+
+func (c *container) UploadTarToContext(ctx context.Context, tar io.Reader, path string) error {
 	return c.docker.CopyToContainer(ctx, c.id, path, onlyReader(tar), types.CopyToContainerOptions{})
 }
 
@@ -321,11 +624,17 @@ func onlyReader(r io.Reader) io.Reader {
 }
 
 func (c *container) StreamFileTo(stream eng.Stream, path string) error {
-	tar, err := tarFile(path, stream, stream.Size, 0755)
+	tar, err := tarFile(path, stream, stream.Remaining(), 0755)
 	if err != nil {
 		return err
 	}
 	if err := c.UploadTarTo(tar, "/"); err != nil {
+		if tarErr := tar.Close(); tarErr != nil {
+			return tarErr
+		}
+		return err
+	}
+	if err := tar.Close(); err != nil {
 		return err
 	}
 	return stream.Close()
@@ -339,7 +648,10 @@ func (c *container) StreamTarTo(stream eng.Stream, path string) error {
 }
 
 func (c *container) StreamFileFrom(path string) (eng.Stream, error) {
-	ctx := context.Background()
+	return c.StreamFileFromContext(context.Background(), path)
+}
+
+func (c *container) StreamFileFromContext(ctx context.Context, path string) (eng.Stream, error) {
 	tar, stat, err := c.docker.CopyFromContainer(ctx, c.id, path)
 	if err != nil {
 		return eng.Stream{}, err
@@ -8,6 +8,9 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
 	"testing/iotest"
 	"time"
 
@@ -34,6 +37,7 @@ var _ = Describe("Container", func() {
 		config     *eng.ContainerConfig
 		entrypoint []string
 		healthTest []string
+		ports      []eng.PortBinding
 		exit       chan struct{}
 		check      chan time.Time
 	)
@@ -41,6 +45,7 @@ var _ = Describe("Container", func() {
 	BeforeEach(func() {
 		entrypoint = []string{"bash"}
 		healthTest = nil
+		ports = nil
 		exit = nil
 		check = nil
 	})
@@ -48,6 +53,8 @@ var _ = Describe("Container", func() {
 	JustBeforeEach(func() {
 		config = &eng.ContainerConfig{
 			Name:       "some-name",
+			AppName:    "some-app",
+			Role:       "some-role",
 			Hostname:   "test-container",
 			Image:      "sclevine/test",
 			Port:       "8080",
@@ -55,6 +62,7 @@ var _ = Describe("Container", func() {
 			Entrypoint: entrypoint,
 			HostIP:     "127.0.0.1",
 			HostPort:   freePort(),
+			Ports:      ports,
 			Test:       healthTest,
 			Interval:   100 * time.Millisecond,
 			Retries:    100,
@@ -85,10 +93,41 @@ var _ = Describe("Container", func() {
 			Expect(info.Name).To(HavePrefix("/some-name-"))
 			Expect(info.Config.Env).To(ContainElement("SOME-KEY=some-value"))
 			Expect(info.Config.Healthcheck.Test).To(Equal([]string{"echo"}))
+			Expect(info.Config.Labels).To(HaveKey(eng.LabelSession))
+			Expect(info.Config.Labels).To(HaveKeyWithValue(eng.LabelRole, "some-role"))
+			Expect(info.Config.Labels).To(HaveKeyWithValue(eng.LabelApp, "some-app"))
+			Expect(info.Config.Labels).To(HaveKeyWithValue(eng.LabelPID, strconv.Itoa(os.Getpid())))
+			Expect(time.Parse(time.RFC3339Nano, info.Config.Labels[eng.LabelCreated])).To(BeTemporally("~", time.Now(), time.Minute))
 			Expect(info.HostConfig.PortBindings).To(Equal(nat.PortMap{
 				"8080/tcp": {{HostIP: "127.0.0.1", HostPort: config.HostPort}},
 			}))
 		})
+
+		Context("with additional port bindings", func() {
+			BeforeEach(func() {
+				ports = []eng.PortBinding{
+					{ContainerPort: "9000", HostIP: "127.0.0.1"},
+					{Protocol: "udp", ContainerPort: "9001-9002", HostIP: "127.0.0.1", HostPort: "19001-19002"},
+				}
+			})
+
+			It("should bind each port", func() {
+				info := containerInfo(contr.ID())
+				Expect(info.Config.ExposedPorts).To(HaveLen(4))
+				Expect(info.HostConfig.PortBindings).To(Equal(nat.PortMap{
+					"8080/tcp": {{HostIP: "127.0.0.1", HostPort: config.HostPort}},
+					"9000/tcp": {{HostIP: "127.0.0.1", HostPort: ""}},
+					"9001/udp": {{HostIP: "127.0.0.1", HostPort: "19001"}},
+					"9002/udp": {{HostIP: "127.0.0.1", HostPort: "19002"}},
+				}))
+			})
+		})
+
+		It("should return an error when port ranges do not match", func() {
+			config.Ports = []eng.PortBinding{{ContainerPort: "9001-9002", HostPort: "19001-19003"}}
+			_, err := engine.NewContainer(config)
+			Expect(err).To(MatchError("invalid port range: 19001-19003:9001-9002"))
+		})
 	})
 
 	Describe("#Close", func() {
@@ -156,6 +195,86 @@ var _ = Describe("Container", func() {
 		})
 	})
 
+	Describe("#Stop", func() {
+		BeforeEach(func() {
+			entrypoint = []string{
+				"sh", "-c",
+				`trap 'echo some-shutdown; exit 3' TERM && \
+				 echo some-startup && \
+				 while true; do sleep 0.1; done`,
+			}
+		})
+
+		It("should signal the container to exit gracefully", func() {
+			wait := testutil.Wait(2)
+			defer wait()
+
+			logs := gbytes.NewBuffer()
+			go func() {
+				defer wait()
+				defer GinkgoRecover()
+				Expect(contr.Start("some-prefix", logs, nil)).To(Equal(int64(3)))
+			}()
+			Eventually(logs).Should(gbytes.Say("some-startup"))
+			Expect(contr.Stop(10 * time.Second)).To(Succeed())
+			Eventually(logs).Should(gbytes.Say("some-shutdown"))
+			Expect(containerRunning(contr.ID())).To(BeFalse())
+		})
+	})
+
+	Describe("#Kill", func() {
+		BeforeEach(func() {
+			exit = make(chan struct{})
+			entrypoint = []string{
+				"sh", "-c",
+				`trap 'echo some-signal' USR1 && \
+				 echo some-startup && \
+				 while true; do sleep 0.1; done`,
+			}
+		})
+
+		It("should send the signal to the container", func() {
+			wait := testutil.Wait(2)
+			defer wait()
+			defer close(exit)
+
+			logs := gbytes.NewBuffer()
+			go func() {
+				defer wait()
+				defer GinkgoRecover()
+				Expect(contr.Start("some-prefix", logs, nil)).To(Equal(int64(128)))
+			}()
+			Eventually(logs).Should(gbytes.Say("some-startup"))
+			Expect(contr.Kill("SIGUSR1")).To(Succeed())
+			Eventually(logs).Should(gbytes.Say("some-signal"))
+			Expect(containerRunning(contr.ID())).To(BeTrue())
+		})
+
+		It("should return an error when the container is not running", func() {
+			Expect(contr.Kill("SIGUSR1")).To(MatchError(ContainSubstring("is not running")))
+		})
+	})
+
+	Describe("#Pause / #Unpause", func() {
+		BeforeEach(func() {
+			entrypoint = []string{"tail", "-f", "/dev/null"}
+		})
+
+		It("should pause and unpause the container", func() {
+			Expect(contr.Background()).To(Succeed())
+			Eventually(try(containerRunning, contr.ID())).Should(BeTrue())
+
+			Expect(contr.Pause()).To(Succeed())
+			Expect(containerInfo(contr.ID()).State.Paused).To(BeTrue())
+			Expect(contr.Unpause()).To(Succeed())
+			Expect(containerInfo(contr.ID()).State.Paused).To(BeFalse())
+		})
+
+		It("should return an error when the container is not running", func() {
+			Expect(contr.Pause()).To(MatchError(ContainSubstring("is not running")))
+		})
+	})
+
 	Describe("#Start", func() {
 		Context("when signaled to exit", func() {
 			BeforeEach(func() {
@@ -185,6 +304,78 @@ var _ = Describe("Container", func() {
 			})
 		})
 
+		Context("when the context is canceled", func() {
+			BeforeEach(func() {
+				entrypoint = []string{"sh", "-c", "echo some-logs-stdout && sleep 60"}
+			})
+
+			It("should start the container, stream logs, and return status 128", func() {
+				wait := testutil.Wait(2)
+				defer wait()
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				logs := gbytes.NewBuffer()
+				go func() {
+					defer wait()
+					defer GinkgoRecover()
+					Expect(contr.StartContext(ctx, "some-prefix", logs, nil)).To(Equal(int64(128)))
+				}()
+				Eventually(try(containerRunning, contr.ID())).Should(BeTrue())
+				Eventually(logs.Contents).Should(ContainSubstring("Z some-logs-stdout"))
+				cancel()
+			})
+		})
+
+		Context("when a log sink is configured", func() {
+			var sink *testSink
+
+			BeforeEach(func() {
+				sink = &testSink{}
+				entrypoint = []string{
+					"sh", "-c",
+					`echo some-logs-stdout && \
+					 sleep 0.1 && \
+					 >&2 echo some-logs-stderr`,
+				}
+			})
+
+			JustBeforeEach(func() {
+				Expect(contr.Close()).To(Succeed())
+				config.AppName = "some-app"
+				config.LogSink = sink
+				var err error
+				contr, err = engine.NewContainer(config)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should send structured log records to the sink", func() {
+				Expect(contr.Start("some-prefix", ioutil.Discard, nil)).To(Equal(int64(0)))
+				Eventually(sink.Records).Should(HaveLen(2))
+				records := sink.Records()
+				Expect(records[0].Stream).To(Equal(eng.Stdout))
+				Expect(records[0].Message).To(Equal("some-logs-stdout"))
+				Expect(records[0].Time).To(BeTemporally("~", time.Now(), 10*time.Second))
+				Expect(records[0].ContainerID).To(Equal(contr.ID()))
+				Expect(records[0].AppName).To(Equal("some-app"))
+				Expect(records[1].Stream).To(Equal(eng.Stderr))
+				Expect(records[1].Message).To(Equal("some-logs-stderr"))
+			})
+
+			Context("when a line is split across frames", func() {
+				BeforeEach(func() {
+					entrypoint = []string{"sh", "-c", `head -c 40000 /dev/zero | tr '\0' a && echo`}
+				})
+
+				It("should send the whole line as one record", func() {
+					Expect(contr.Start("some-prefix", ioutil.Discard, nil)).To(Equal(int64(0)))
+					Eventually(sink.Records).Should(HaveLen(1))
+					Expect(sink.Records()[0].Message).To(Equal(strings.Repeat("a", 40000)))
+				})
+			})
+		})
+
 		Context("when signaled to restart", func() {
 			BeforeEach(func() {
 				exit = make(chan struct{})
@@ -348,6 +539,39 @@ var _ = Describe("Container", func() {
 		})
 	})
 
+	Describe("#Exec", func() {
+		BeforeEach(func() {
+			entrypoint = []string{"tail", "-f", "/dev/null"}
+		})
+
+		It("should run a command in the container without a TTY and return its status", func() {
+			Expect(contr.Background()).To(Succeed())
+			Eventually(try(containerRunning, contr.ID())).Should(BeTrue())
+
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			status, err := contr.Exec(&eng.ExecConfig{
+				Cmd: []string{
+					"sh", "-c",
+					`echo "$SOME_EXEC_KEY $(pwd)" && >&2 cat && exit 3`,
+				},
+				Env:        []string{"SOME_EXEC_KEY=some-exec-value"},
+				WorkingDir: "/tmp",
+				Stdin:      bytes.NewBufferString("some-stdin"),
+				Stdout:     stdout,
+				Stderr:     stderr,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(status).To(Equal(int64(3)))
+			Expect(stdout.String()).To(Equal("some-exec-value /tmp\n"))
+			Expect(stderr.String()).To(Equal("some-stdin"))
+		})
+
+		It("should return an error when the container is not running", func() {
+			_, err := contr.Exec(&eng.ExecConfig{Cmd: []string{"true"}})
+			Expect(err).To(MatchError(ContainSubstring("is not running")))
+		})
+	})
+
 	Describe("#HealthCheck", func() {
 		Context("when the container reaches a healthy state", func() {
 			BeforeEach(func() {
@@ -374,6 +598,82 @@ var _ = Describe("Container", func() {
 		})
 	})
 
+	Describe("#Network", func() {
+		BeforeEach(func() {
+			entrypoint = []string{"tail", "-f", "/dev/null"}
+			ports = []eng.PortBinding{{ContainerPort: "9000", HostIP: "127.0.0.1"}}
+		})
+
+		It("should return the assigned host ports and IP addresses of the running container", func() {
+			Expect(contr.Background()).To(Succeed())
+
+			network, err := contr.Network()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(network.IPAddresses).To(HaveKey("bridge"))
+			Expect(network.Ports).To(HaveLen(2))
+			Expect(network.Ports[0]).To(Equal(eng.PortBinding{
+				Protocol:      "tcp",
+				ContainerPort: "8080",
+				HostIP:        "127.0.0.1",
+				HostPort:      config.HostPort,
+			}))
+			Expect(network.Ports[1].ContainerPort).To(Equal("9000"))
+			Expect(network.Ports[1].HostPort).NotTo(BeEmpty())
+		})
+
+		It("should return the ports of the network container when sharing its network", func() {
+			Expect(contr.Background()).To(Succeed())
+			appContr, err := engine.NewContainer(&eng.ContainerConfig{
+				Name:         "some-app",
+				Image:        "sclevine/test",
+				Entrypoint:   []string{"bash"},
+				NetContainer: contr.ID(),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			defer appContr.Close()
+
+			network, err := appContr.Network()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(network.Ports).To(HaveLen(2))
+			Expect(network.Ports[0].HostPort).To(Equal(config.HostPort))
+		})
+	})
+
+	Describe("#Stats", func() {
+		BeforeEach(func() {
+			exit = make(chan struct{})
+			entrypoint = []string{"tail", "-f", "/dev/null"}
+		})
+
+		It("should stream resource usage until signaled to exit", func() {
+			Expect(contr.Background()).To(Succeed())
+			stats := contr.Stats()
+
+			var sample eng.Stats
+			Eventually(stats, "5s").Should(Receive(&sample))
+			Expect(sample.Time).NotTo(BeZero())
+			Expect(sample.MemoryUsage).To(BeNumerically(">", 0))
+			Expect(sample.MemoryLimit).To(BeNumerically(">=", sample.MemoryUsage))
+			Expect(sample.PIDs).To(BeNumerically(">", 0))
+
+			close(exit)
+			Eventually(func() bool {
+				_, ok := <-stats
+				return ok
+			}, "5s").Should(BeFalse())
+		})
+
+		It("should send an error when resource usage cannot be read", func() {
+			Expect(contr.Close()).To(Succeed())
+			stats := contr.Stats()
+
+			var sample eng.Stats
+			Eventually(stats, "5s").Should(Receive(&sample))
+			Expect(sample.Err).To(MatchError(ContainSubstring("No such container")))
+			Eventually(stats, "5s").Should(BeClosed())
+		})
+	})
+
 	Describe("#Commit", func() {
 		It("should create an image using the state of the container", func() {
 			ctx := context.Background()
@@ -395,6 +695,10 @@ var _ = Describe("Container", func() {
 			info, _, err := client.ImageInspectWithRaw(ctx, id)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(info.Config.Hostname).To(Equal("test-container"))
+			Expect(info.Config.Labels).To(HaveKeyWithValue(eng.LabelRole, "some-role"))
+			Expect(info.Config.Labels).To(HaveKeyWithValue(eng.LabelApp, "some-app"))
+			Expect(info.Config.Labels[eng.LabelHost]).To(BeEmpty())
+			Expect(info.Config.Labels[eng.LabelPID]).To(BeEmpty())
 
 			contr2, err := engine.NewContainer(&eng.ContainerConfig{
 				Name:       "some-name",
@@ -541,6 +845,23 @@ var _ = Describe("Container", func() {
 			// TODO: test closing of tar
 		})
 
+		It("should stream the file as executable, owned by root, and modified now", func() {
+			start := time.Now().Add(-time.Second)
+			inBuffer := bytes.NewBufferString("some-data")
+			inStream := eng.NewStream(ioutil.NopCloser(inBuffer), int64(inBuffer.Len()))
+			Expect(contr.StreamFileTo(inStream, "/some-path/some-file")).To(Succeed())
+
+			tarResult, err := contr.StreamTarFrom("/some-path/some-file")
+			Expect(err).NotTo(HaveOccurred())
+			defer tarResult.Close()
+			header, err := tar.NewReader(tarResult).Next()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(header.Mode).To(Equal(int64(0100755)))
+			Expect(header.Uid).To(Equal(0))
+			Expect(header.Gid).To(Equal(0))
+			Expect(header.ModTime).To(BeTemporally(">=", start.Truncate(time.Second)))
+		})
+
 		It("should return an error if tarring fails", func() {
 			inBuffer := bytes.NewBufferString("some-data")
 			inStream := eng.NewStream(&closeTester{Reader: inBuffer}, 100)
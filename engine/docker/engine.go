@@ -4,22 +4,54 @@ import (
 	"archive/tar"
 	"bytes"
 	"io"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	docker "github.com/docker/docker/client"
+	gouuid "github.com/nu7hatch/gouuid"
 
 	eng "github.com/buildpack/forge/engine"
+	"github.com/buildpack/forge/engine/docker/auth"
 )
 
 type engine struct {
-	proxy  eng.ProxyConfig
-	exit   <-chan struct{}
-	docker *docker.Client
+	proxy   eng.ProxyConfig
+	exit    <-chan struct{}
+	docker  *docker.Client
+	session string
+	host    string
+
+	authOnce sync.Once
+	auth     *auth.Config
+	authErr  error
 }
 
 func New(config *eng.EngineConfig) (eng.Engine, error) {
+	uuid, err := gouuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
 	client, err := docker.NewEnvClient()
-	return &engine{config.Proxy, config.Exit, client}, err
+	return &engine{
+		proxy:   config.Proxy,
+		exit:    config.Exit,
+		docker:  client,
+		session: uuid.String(),
+		host:    host,
+	}, err
+}
+
+// authConfig loads the docker config on first use, so that an invalid
+// config only fails the operations that need registry credentials.
+func (e *engine) authConfig() (*auth.Config, error) {
+	e.authOnce.Do(func() {
+		e.auth, e.authErr = auth.Load(auth.Dir())
+	})
+	return e.auth, e.authErr
 }
 
 func (e *engine) Close() error {
@@ -39,6 +71,27 @@ func (e *engine) proxyEnv(config *eng.ContainerConfig) []string {
 	return env
 }
 
+func (e *engine) imageLabels(role, appName string) map[string]string {
+	labels := map[string]string{
+		eng.LabelSession: e.session,
+		eng.LabelCreated: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if role != "" {
+		labels[eng.LabelRole] = role
+	}
+	if appName != "" {
+		labels[eng.LabelApp] = appName
+	}
+	return labels
+}
+
+func (e *engine) containerLabels(role, appName string) map[string]string {
+	labels := e.imageLabels(role, appName)
+	labels[eng.LabelHost] = e.host
+	labels[eng.LabelPID] = strconv.Itoa(os.Getpid())
+	return labels
+}
+
 func appendProxy(env []string, k, v string) []string {
 	if v == "" {
 		return env
@@ -49,16 +102,59 @@ func appendProxy(env []string, k, v string) []string {
 	)
 }
 
-func tarFile(name string, contents io.Reader, size, mode int64) (io.Reader, error) {
-	tarBuffer := &bytes.Buffer{}
-	tarball := tar.NewWriter(tarBuffer)
-	defer tarball.Close()
-	header := &tar.Header{Name: name, Size: size, Mode: mode}
+// tarFile streams a tarball containing a single file owned by root as it is read.
+// Closing the stream stops reading contents and returns any error encountered while reading them.
+func tarFile(name string, contents io.Reader, size, mode int64) (eng.Stream, error) {
+	header := &tar.Header{
+		Name:     name,
+		Typeflag: tar.TypeReg,
+		Size:     size,
+		Mode:     mode,
+		ModTime:  time.Now(),
+	}
+	headerBuffer := &bytes.Buffer{}
+	if err := tar.NewWriter(headerBuffer).WriteHeader(header); err != nil {
+		return eng.Stream{}, err
+	}
+	const blockSize = 512
+	tarSize := int64(headerBuffer.Len()) + (size+blockSize-1)/blockSize*blockSize + 2*blockSize
+
+	reader, writer := io.Pipe()
+	stream := &tarStream{PipeReader: reader, done: make(chan struct{})}
+	go func() {
+		defer close(stream.done)
+		stream.err = writeTarFile(writer, header, contents)
+		if stream.err == io.EOF {
+			writer.CloseWithError(io.ErrUnexpectedEOF)
+			return
+		}
+		writer.CloseWithError(stream.err)
+	}()
+	return eng.NewStream(stream, tarSize), nil
+}
+
+func writeTarFile(w io.Writer, header *tar.Header, contents io.Reader) error {
+	tarball := tar.NewWriter(w)
 	if err := tarball.WriteHeader(header); err != nil {
-		return nil, err
+		return err
 	}
-	if _, err := io.CopyN(tarball, contents, size); err != nil {
-		return nil, err
+	if _, err := io.CopyN(tarball, contents, header.Size); err != nil {
+		return err
+	}
+	return tarball.Close()
+}
+
+type tarStream struct {
+	*io.PipeReader
+	done chan struct{}
+	err  error
+}
+
+func (t *tarStream) Close() error {
+	t.PipeReader.Close()
+	<-t.done
+	if t.err == io.ErrClosedPipe {
+		return nil
 	}
-	return tarBuffer, nil
+	return t.err
 }
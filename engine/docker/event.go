@@ -0,0 +1,70 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+
+	eng "github.com/buildpack/forge/engine"
+)
+
+func (e *engine) Events() <-chan eng.Event {
+	return e.EventsContext(context.Background())
+}
+
+// EventsContext receives all events that occur after it is called,
+// even those that occur before the subscription to the daemon is established.
+func (e *engine) EventsContext(ctx context.Context) <-chan eng.Event {
+	since := time.Now()
+	out := make(chan eng.Event)
+	go func() {
+		defer close(out)
+		ctx, cancel := withExit(ctx, e.exit)
+		defer cancel()
+
+		messages, errs := e.docker.Events(ctx, types.EventsOptions{
+			Since: fmt.Sprintf("%d.%09d", since.Unix(), since.Nanosecond()),
+			Filters: filters.NewArgs(
+				filters.Arg("type", events.ContainerEventType),
+				filters.Arg("label", eng.LabelSession+"="+e.session),
+			),
+		})
+		for {
+			select {
+			case message := <-messages:
+				select {
+				case out <- convertEvent(message):
+				case <-ctx.Done():
+					return
+				}
+			case <-errs:
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func convertEvent(m events.Message) eng.Event {
+	event := eng.Event{
+		Action:      eng.EventAction(m.Action),
+		ContainerID: m.Actor.ID,
+		Name:        m.Actor.Attributes["name"],
+		Image:       m.Actor.Attributes["image"],
+		Time:        time.Unix(0, m.TimeNano),
+	}
+	if strings.HasPrefix(m.Action, string(eng.EventHealthStatus)+":") {
+		event.Action = eng.EventHealthStatus
+		event.Health = strings.TrimSpace(strings.TrimPrefix(m.Action, string(eng.EventHealthStatus)+":"))
+	}
+	if code, err := strconv.ParseInt(m.Actor.Attributes["exitCode"], 10, 64); err == nil {
+		event.ExitCode = code
+	}
+	return event
+}
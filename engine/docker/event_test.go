@@ -0,0 +1,53 @@
+package docker_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	eng "github.com/buildpack/forge/engine"
+)
+
+var _ = Describe("Engine", func() {
+	Describe("#EventsContext", func() {
+		It("should stream lifecycle events for containers created by the engine", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			events := engine.EventsContext(ctx)
+
+			contr, err := engine.NewContainer(&eng.ContainerConfig{
+				Name:       "some-name",
+				Image:      "sclevine/test",
+				Entrypoint: []string{"sh", "-c", "exit 3"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(contr.Background()).To(Succeed())
+
+			var event eng.Event
+			Eventually(events, "5s").Should(Receive(&event))
+			Expect(event.Action).To(Equal(eng.EventCreate))
+			Expect(event.ContainerID).To(Equal(contr.ID()))
+			Expect(event.Name).To(HavePrefix("some-name-"))
+			Expect(event.Image).To(Equal("sclevine/test"))
+			Expect(event.Time).NotTo(BeZero())
+
+			Eventually(events, "5s").Should(Receive(&event))
+			Expect(event.Action).To(Equal(eng.EventStart))
+
+			Eventually(events, "5s").Should(Receive(&event))
+			Expect(event.Action).To(Equal(eng.EventDie))
+			Expect(event.ExitCode).To(Equal(int64(3)))
+
+			Expect(contr.Close()).To(Succeed())
+			Eventually(events, "5s").Should(Receive(&event))
+			Expect(event.Action).To(Equal(eng.EventDestroy))
+
+			cancel()
+			Eventually(func() bool {
+				_, ok := <-events
+				return ok
+			}, "5s").Should(BeFalse())
+		})
+	})
+})
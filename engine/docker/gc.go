@@ -0,0 +1,92 @@
+package docker
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+
+	eng "github.com/buildpack/forge/engine"
+)
+
+func (e *engine) GC(config *eng.GCConfig) (orphans []eng.Resource, err error) {
+	return e.GCContext(context.Background(), config)
+}
+
+func (e *engine) GCContext(ctx context.Context, config *eng.GCConfig) (orphans []eng.Resource, err error) {
+	containers, err := e.docker.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", eng.LabelPID)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range containers {
+		if !e.orphaned(c.Labels, config.MaxAge) {
+			continue
+		}
+		if !config.DryRun {
+			if err := e.docker.ContainerRemove(ctx, c.ID, types.ContainerRemoveOptions{
+				Force: true,
+			}); err != nil {
+				return orphans, err
+			}
+		}
+		orphans = append(orphans, eng.NewResource(eng.ContainerResource, c.ID, c.Labels))
+	}
+
+	images, err := e.docker.ImageList(ctx, types.ImageListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", eng.LabelSession),
+			filters.Arg("dangling", "true"),
+		),
+	})
+	if err != nil {
+		return orphans, err
+	}
+	for _, img := range images {
+		if config.MaxAge == 0 || img.Labels[eng.LabelSession] == e.session || !olderThan(img.Labels, config.MaxAge) {
+			continue
+		}
+		if !config.DryRun {
+			if _, err := e.docker.ImageRemove(ctx, img.ID, types.ImageRemoveOptions{
+				Force:         true,
+				PruneChildren: true,
+			}); err != nil {
+				return orphans, err
+			}
+		}
+		orphans = append(orphans, eng.NewResource(eng.ImageResource, img.ID, img.Labels))
+	}
+	return orphans, nil
+}
+
+// Containers are orphaned when their session was started by a process on
+// this host that is no longer running, or when they are older than maxAge.
+func (e *engine) orphaned(labels map[string]string, maxAge time.Duration) bool {
+	if labels[eng.LabelSession] == e.session {
+		return false
+	}
+	if maxAge > 0 && olderThan(labels, maxAge) {
+		return true
+	}
+	if labels[eng.LabelHost] != e.host {
+		return false
+	}
+	pid, err := strconv.Atoi(labels[eng.LabelPID])
+	if err != nil || pid == os.Getpid() {
+		return false
+	}
+	return !processRunning(pid)
+}
+
+func olderThan(labels map[string]string, maxAge time.Duration) bool {
+	created, err := time.Parse(time.RFC3339Nano, labels[eng.LabelCreated])
+	if err != nil {
+		return false
+	}
+	return time.Since(created) > maxAge
+}
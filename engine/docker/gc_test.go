@@ -0,0 +1,158 @@
+package docker_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	cont "github.com/docker/docker/api/types/container"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	eng "github.com/buildpack/forge/engine"
+)
+
+var _ = Describe("Engine", func() {
+	Describe("#GC", func() {
+		var (
+			ctx      context.Context
+			contr    eng.Container
+			deadPID  string
+			hostname string
+		)
+
+		BeforeEach(func() {
+			ctx = context.Background()
+
+			cmd := exec.Command("true")
+			Expect(cmd.Run()).To(Succeed())
+			deadPID = strconv.Itoa(cmd.Process.Pid)
+
+			var err error
+			hostname, err = os.Hostname()
+			Expect(err).NotTo(HaveOccurred())
+
+			contr, err = engine.NewContainer(&eng.ContainerConfig{
+				Name:       "some-name",
+				Image:      "sclevine/test",
+				Entrypoint: []string{"bash"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(contr.Close()).To(Succeed())
+		})
+
+		orphan := func(labels map[string]string) string {
+			response, err := client.ContainerCreate(ctx, &cont.Config{
+				Image:      "sclevine/test",
+				Entrypoint: []string{"bash"},
+				Labels:     labels,
+			}, nil, nil, "")
+			Expect(err).NotTo(HaveOccurred())
+			return response.ID
+		}
+
+		It("should remove containers from dead sessions on this host", func() {
+			dead := orphan(map[string]string{
+				eng.LabelSession: "some-dead-session",
+				eng.LabelRole:    eng.RoleStaging,
+				eng.LabelApp:     "some-app",
+				eng.LabelCreated: time.Now().UTC().Format(time.RFC3339Nano),
+				eng.LabelHost:    hostname,
+				eng.LabelPID:     deadPID,
+			})
+			live := orphan(map[string]string{
+				eng.LabelSession: "some-live-session",
+				eng.LabelCreated: time.Now().UTC().Format(time.RFC3339Nano),
+				eng.LabelHost:    hostname,
+				eng.LabelPID:     strconv.Itoa(os.Getppid()),
+			})
+			defer client.ContainerRemove(ctx, live, types.ContainerRemoveOptions{Force: true})
+
+			orphans, err := engine.GC(&eng.GCConfig{DryRun: true})
+			Expect(err).NotTo(HaveOccurred())
+			resource, ok := findResource(orphans, dead)
+			Expect(ok).To(BeTrue())
+			Expect(resource.Type).To(Equal(eng.ContainerResource))
+			Expect(resource.Session).To(Equal("some-dead-session"))
+			Expect(resource.Role).To(Equal(eng.RoleStaging))
+			Expect(resource.AppName).To(Equal("some-app"))
+			Expect(resource.Created).To(BeTemporally("~", time.Now(), time.Minute))
+			Expect(containerFound(dead)).To(BeTrue())
+
+			orphans, err = engine.GC(&eng.GCConfig{})
+			Expect(err).NotTo(HaveOccurred())
+			_, ok = findResource(orphans, dead)
+			Expect(ok).To(BeTrue())
+			_, ok = findResource(orphans, live)
+			Expect(ok).To(BeFalse())
+			Expect(containerFound(dead)).To(BeFalse())
+			Expect(containerFound(live)).To(BeTrue())
+			Expect(containerFound(contr.ID())).To(BeTrue())
+		})
+
+		It("should only remove untagged images from other sessions older than the max age", func() {
+			commit := func(labels map[string]string) string {
+				id := orphan(nil)
+				defer client.ContainerRemove(ctx, id, types.ContainerRemoveOptions{Force: true})
+				response, err := client.ContainerCommit(ctx, id, types.ContainerCommitOptions{
+					Config: &cont.Config{Labels: labels},
+				})
+				Expect(err).NotTo(HaveOccurred())
+				return response.ID
+			}
+			old := commit(map[string]string{
+				eng.LabelSession: "some-dead-session",
+				eng.LabelCreated: time.Now().Add(-time.Hour).UTC().Format(time.RFC3339Nano),
+			})
+			recent := commit(map[string]string{
+				eng.LabelSession: "some-dead-session",
+				eng.LabelCreated: time.Now().UTC().Format(time.RFC3339Nano),
+			})
+			defer client.ImageRemove(ctx, recent, types.ImageRemoveOptions{Force: true})
+
+			orphans, err := engine.GC(&eng.GCConfig{})
+			Expect(err).NotTo(HaveOccurred())
+			_, ok := findResource(orphans, old)
+			Expect(ok).To(BeFalse())
+
+			orphans, err = engine.GC(&eng.GCConfig{MaxAge: time.Minute})
+			Expect(err).NotTo(HaveOccurred())
+			resource, ok := findResource(orphans, old)
+			Expect(ok).To(BeTrue())
+			Expect(resource.Type).To(Equal(eng.ImageResource))
+			_, ok = findResource(orphans, recent)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should remove containers from other sessions older than the max age", func() {
+			old := orphan(map[string]string{
+				eng.LabelSession: "some-remote-session",
+				eng.LabelCreated: time.Now().Add(-time.Hour).UTC().Format(time.RFC3339Nano),
+				eng.LabelHost:    "some-remote-host",
+				eng.LabelPID:     "1",
+			})
+			recent := orphan(map[string]string{
+				eng.LabelSession: "some-remote-session",
+				eng.LabelCreated: time.Now().UTC().Format(time.RFC3339Nano),
+				eng.LabelHost:    "some-remote-host",
+				eng.LabelPID:     "1",
+			})
+			defer client.ContainerRemove(ctx, recent, types.ContainerRemoveOptions{Force: true})
+
+			orphans, err := engine.GC(&eng.GCConfig{MaxAge: time.Minute})
+			Expect(err).NotTo(HaveOccurred())
+			_, ok := findResource(orphans, old)
+			Expect(ok).To(BeTrue())
+			_, ok = findResource(orphans, recent)
+			Expect(ok).To(BeFalse())
+			Expect(containerFound(old)).To(BeFalse())
+			Expect(containerFound(recent)).To(BeTrue())
+		})
+	})
+})
@@ -5,84 +5,317 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"io"
+	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
 	docker "github.com/docker/docker/client"
 
 	eng "github.com/buildpack/forge/engine"
+	"github.com/buildpack/forge/engine/docker/archive"
 )
 
 type image struct {
 	exit   <-chan struct{}
 	docker *docker.Client
+	engine *engine
 }
 
 func (e *engine) NewImage() eng.Image {
-	return &image{e.exit, e.docker}
+	return &image{e.exit, e.docker, e}
 }
 
 func (i *image) Build(tag string, dockerfile eng.Stream) <-chan eng.Progress {
+	return i.BuildContext(context.Background(), tag, dockerfile)
+}
+
+func (i *image) BuildContext(ctx context.Context, tag string, dockerfile eng.Stream) <-chan eng.Progress {
 	defer dockerfile.Close()
-	ctx := context.Background()
+	dockerfileTar, err := tarFile("Dockerfile", dockerfile, dockerfile.Remaining(), 0644)
+	if err != nil {
+		progress := make(chan eng.Progress, 1)
+		progress <- progressError(err)
+		close(progress)
+		return progress
+	}
+	defer dockerfileTar.Close()
+	return i.BuildFromContext(ctx, &eng.BuildConfig{
+		Context:    dockerfileTar,
+		Tags:       []string{tag},
+		PullParent: true,
+	})
+}
+
+func (i *image) BuildFrom(config *eng.BuildConfig) <-chan eng.Progress {
+	return i.BuildFromContext(context.Background(), config)
+}
+
+func (i *image) BuildFromContext(ctx context.Context, config *eng.BuildConfig) <-chan eng.Progress {
 	progress := make(chan eng.Progress, 1)
 
-	dockerfileTar, err := tarFile("Dockerfile", dockerfile, dockerfile.Size, 0644)
+	dockerfile := config.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+	buildContext := config.Context
+	if buildContext == nil {
+		tar, err := archive.TarContext(config.ContextDir, dockerfile)
+		if err != nil {
+			progress <- progressError(err)
+			close(progress)
+			return progress
+		}
+		defer tar.Close()
+		buildContext = tar
+	}
+	labels := i.engine.imageLabels(eng.RoleBuild, "")
+	for k, v := range config.Labels {
+		labels[k] = v
+	}
+	authConfigs, err := i.authConfigs()
 	if err != nil {
-		progress <- progressError{err}
+		progress <- progressError(err)
 		close(progress)
 		return progress
 	}
-	response, err := i.docker.ImageBuild(ctx, dockerfileTar, types.ImageBuildOptions{
-		Tags:        []string{tag},
-		PullParent:  true,
+	buildArgs := map[string]*string{}
+	for k, v := range config.BuildArgs {
+		v := v
+		buildArgs[k] = &v
+	}
+	response, err := i.docker.ImageBuild(ctx, buildContext, types.ImageBuildOptions{
+		Tags:        config.Tags,
+		Dockerfile:  dockerfile,
+		BuildArgs:   buildArgs,
+		Labels:      labels,
+		Target:      config.Target,
+		NoCache:     config.NoCache,
+		PullParent:  config.PullParent,
+		NetworkMode: config.NetworkMode,
+		AuthConfigs: authConfigs,
 		Remove:      true,
 		ForceRemove: true,
 	})
 	if err != nil {
-		progress <- progressError{err}
+		progress <- progressError(err)
 		close(progress)
 		return progress
 	}
-	go i.checkBody(response.Body, progress)
+	go i.checkBody(ctx, response.Body, progress)
 	return progress
 }
 
 func (i *image) Pull(ref string) <-chan eng.Progress {
-	ctx := context.Background()
+	return i.PullContext(context.Background(), ref)
+}
+
+func (i *image) PullContext(ctx context.Context, ref string) <-chan eng.Progress {
 	progress := make(chan eng.Progress, 1)
 
-	body, err := i.docker.ImagePull(ctx, ref, types.ImagePullOptions{})
+	registryAuth, err := i.registryAuth(ref, eng.RegistryCreds{})
 	if err != nil {
-		progress <- progressError{err}
+		progress <- progressError(err)
 		close(progress)
 		return progress
 	}
-	go i.checkBody(body, progress)
+	body, err := i.docker.ImagePull(ctx, ref, types.ImagePullOptions{
+		RegistryAuth: registryAuth,
+	})
+	if err != nil {
+		progress <- progressError(err)
+		close(progress)
+		return progress
+	}
+	go i.checkBody(ctx, body, progress)
 	return progress
 }
 
 func (i *image) Push(ref string, creds eng.RegistryCreds) <-chan eng.Progress {
-	ctx := context.Background()
+	return i.PushContext(context.Background(), ref, creds)
+}
+
+func (i *image) PushContext(ctx context.Context, ref string, creds eng.RegistryCreds) <-chan eng.Progress {
 	progress := make(chan eng.Progress, 1)
 
-	credsJSON, err := json.Marshal(creds)
+	registryAuth, err := i.registryAuth(ref, creds)
 	if err != nil {
-		progress <- progressError{err}
+		progress <- progressError(err)
 		close(progress)
 		return progress
 	}
 	body, err := i.docker.ImagePush(ctx, ref, types.ImagePushOptions{
-		RegistryAuth: base64.StdEncoding.EncodeToString(credsJSON),
+		RegistryAuth: registryAuth,
 	})
 	if err != nil {
-		progress <- progressError{err}
+		progress <- progressError(err)
+		close(progress)
+		return progress
+	}
+	go i.checkBody(ctx, body, progress)
+	return progress
+}
+
+func (i *image) Save(progress chan<- eng.Progress, refs ...string) (eng.Stream, error) {
+	return i.SaveContext(context.Background(), progress, refs...)
+}
+
+// SaveContext streams the archive from the daemon as it is read, so its size is unknown.
+func (i *image) SaveContext(ctx context.Context, progress chan<- eng.Progress, refs ...string) (eng.Stream, error) {
+	body, err := i.docker.ImageSave(ctx, refs)
+	if err != nil {
+		return eng.Stream{}, err
+	}
+	return eng.NewProgressStream(eng.NewStream(body, -1), "save", progress), nil
+}
+
+func (i *image) Load(image eng.Stream) <-chan eng.Progress {
+	return i.LoadContext(context.Background(), image)
+}
+
+func (i *image) LoadContext(ctx context.Context, image eng.Stream) <-chan eng.Progress {
+	progress := make(chan eng.Progress, 1)
+
+	response, err := i.docker.ImageLoad(ctx, image, false)
+	if err != nil {
+		image.Close()
+		progress <- progressError(err)
 		close(progress)
 		return progress
 	}
-	go i.checkBody(body, progress)
+	loadProgress := make(chan eng.Progress)
+	go i.checkBody(ctx, response.Body, loadProgress)
+	go func() {
+		defer close(progress)
+		defer image.Close()
+		loaded := map[string]bool{}
+		for p := range loadProgress {
+			progress <- p
+			id, err := i.loadedID(ctx, p.Stream)
+			if err != nil {
+				progress <- progressError(err)
+				continue
+			}
+			if id != "" && !loaded[id] {
+				loaded[id] = true
+				progress <- eng.Progress{Aux: &eng.ProgressAux{ImageID: id}}
+			}
+		}
+	}()
 	return progress
 }
 
+func (i *image) loadedID(ctx context.Context, stream string) (string, error) {
+	line := strings.TrimSpace(stream)
+	if strings.HasPrefix(line, "Loaded image ID: ") {
+		return strings.TrimPrefix(line, "Loaded image ID: "), nil
+	}
+	if strings.HasPrefix(line, "Loaded image: ") {
+		info, _, err := i.docker.ImageInspectWithRaw(ctx, strings.TrimPrefix(line, "Loaded image: "))
+		return info.ID, err
+	}
+	return "", nil
+}
+
+func (i *image) Inspect(ref string) (*eng.ImageInfo, error) {
+	return i.InspectContext(context.Background(), ref)
+}
+
+func (i *image) InspectContext(ctx context.Context, ref string) (*eng.ImageInfo, error) {
+	info, _, err := i.docker.ImageInspectWithRaw(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	created, err := time.Parse(time.RFC3339Nano, info.Created)
+	if err != nil {
+		return nil, err
+	}
+	imageInfo := &eng.ImageInfo{
+		ID:           info.ID,
+		RepoTags:     info.RepoTags,
+		RepoDigests:  info.RepoDigests,
+		OS:           info.Os,
+		Architecture: info.Architecture,
+		Created:      created,
+		Size:         info.Size,
+		Layers:       info.RootFS.Layers,
+	}
+	if config := info.Config; config != nil {
+		imageInfo.Labels = config.Labels
+		imageInfo.Env = config.Env
+		imageInfo.Entrypoint = config.Entrypoint
+		imageInfo.Cmd = config.Cmd
+		imageInfo.WorkingDir = config.WorkingDir
+		imageInfo.User = config.User
+	}
+	history, err := i.docker.ImageHistory(ctx, info.ID)
+	if err != nil {
+		return nil, err
+	}
+	// the daemon only reports the size of each layer, so empty layers are those without changes
+	for j := len(history) - 1; j >= 0; j-- {
+		imageInfo.History = append(imageInfo.History, eng.ImageHistory{
+			Created:    time.Unix(history[j].Created, 0).UTC(),
+			CreatedBy:  history[j].CreatedBy,
+			Comment:    history[j].Comment,
+			EmptyLayer: history[j].Size == 0,
+		})
+	}
+	return imageInfo, nil
+}
+
+func (i *image) List(labels map[string]string) ([]eng.ImageInfo, error) {
+	return i.ListContext(context.Background(), labels)
+}
+
+func (i *image) ListContext(ctx context.Context, labels map[string]string) ([]eng.ImageInfo, error) {
+	args := filters.NewArgs()
+	for k, v := range labels {
+		if v == "" {
+			args.Add("label", k)
+		} else {
+			args.Add("label", k+"="+v)
+		}
+	}
+	summaries, err := i.docker.ImageList(ctx, types.ImageListOptions{Filters: args})
+	if err != nil {
+		return nil, err
+	}
+	var images []eng.ImageInfo
+	for _, summary := range summaries {
+		images = append(images, eng.ImageInfo{
+			ID:          summary.ID,
+			RepoTags:    summary.RepoTags,
+			RepoDigests: summary.RepoDigests,
+			Labels:      summary.Labels,
+			Created:     time.Unix(summary.Created, 0),
+			Size:        summary.Size,
+		})
+	}
+	return images, nil
+}
+
+func (i *image) Tag(ref, tag string) error {
+	return i.TagContext(context.Background(), ref, tag)
+}
+
+func (i *image) TagContext(ctx context.Context, ref, tag string) error {
+	return i.docker.ImageTag(ctx, ref, tag)
+}
+
+func (i *image) Exists(ref string) (bool, error) {
+	return i.ExistsContext(context.Background(), ref)
+}
+
+func (i *image) ExistsContext(ctx context.Context, ref string) (bool, error) {
+	if _, _, err := i.docker.ImageInspectWithRaw(ctx, ref); docker.IsErrNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func (i *image) Delete(id string) error {
 	ctx := context.Background()
 	_, err := i.docker.ImageRemove(ctx, id, types.ImageRemoveOptions{
@@ -92,7 +325,7 @@ func (i *image) Delete(id string) error {
 	return err
 }
 
-func (i *image) checkBody(body io.ReadCloser, progress chan<- eng.Progress) {
+func (i *image) checkBody(ctx context.Context, body io.ReadCloser, progress chan<- eng.Progress) {
 	defer body.Close()
 	defer close(progress)
 
@@ -102,14 +335,25 @@ func (i *image) checkBody(body io.ReadCloser, progress chan<- eng.Progress) {
 		case <-i.exit:
 			progress <- progressErrorString("interrupted")
 			return
+		case <-ctx.Done():
+			progress <- progressError(ctx.Err())
+			return
 		default:
 			var stream struct {
-				Error    string
-				Progress string
+				ID             string
+				Status         string
+				Stream         string
+				Progress       string
+				ProgressDetail struct{ Current, Total int64 }
+				Error          string
+				Aux            *struct {
+					ID, Tag, Digest string
+					Size            int64
+				}
 			}
 			if err := decoder.Decode(&stream); err != nil {
 				if err != io.EOF {
-					progress <- progressError{err}
+					progress <- progressError(err)
 				}
 				return
 			}
@@ -117,11 +361,66 @@ func (i *image) checkBody(body io.ReadCloser, progress chan<- eng.Progress) {
 				progress <- progressErrorString(stream.Error)
 				return
 			}
-			if stream.Progress == "" {
-				progress <- progressNA{}
-			} else {
-				progress <- progressMsg(stream.Progress)
+			p := eng.Progress{
+				ID:      stream.ID,
+				Message: stream.Status,
+				Bar:     stream.Progress,
+				Current: stream.ProgressDetail.Current,
+				Total:   stream.ProgressDetail.Total,
+				Stream:  stream.Stream,
 			}
+			if aux := stream.Aux; aux != nil {
+				p.Aux = &eng.ProgressAux{
+					ImageID: aux.ID,
+					Tag:     aux.Tag,
+					Digest:  aux.Digest,
+					Size:    aux.Size,
+				}
+			}
+			progress <- p
+		}
+	}
+}
+
+// registryAuth falls back to the docker config for ref when creds are empty.
+func (i *image) registryAuth(ref string, creds eng.RegistryCreds) (string, error) {
+	if creds == (eng.RegistryCreds{}) {
+		config, err := i.engine.authConfig()
+		if err != nil {
+			return "", err
+		}
+		if creds, err = config.Creds(ref); err != nil {
+			return "", err
+		}
+	}
+	if creds == (eng.RegistryCreds{}) {
+		return "", nil
+	}
+	credsJSON, err := json.Marshal(creds)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(credsJSON), nil
+}
+
+func (i *image) authConfigs() (map[string]types.AuthConfig, error) {
+	config, err := i.engine.authConfig()
+	if err != nil {
+		return nil, err
+	}
+	all, err := config.AllCreds()
+	if err != nil {
+		return nil, err
+	}
+	configs := map[string]types.AuthConfig{}
+	for server, creds := range all {
+		configs[server] = types.AuthConfig{
+			Username:      creds.Username,
+			Password:      creds.Password,
+			Email:         creds.Email,
+			ServerAddress: creds.ServerAddress,
+			IdentityToken: creds.IdentityToken,
 		}
 	}
+	return configs, nil
 }
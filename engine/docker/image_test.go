@@ -5,6 +5,9 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
 
 	docker "github.com/docker/docker/client"
 	gouuid "github.com/nu7hatch/gouuid"
@@ -12,6 +15,8 @@ import (
 	. "github.com/onsi/gomega"
 
 	eng "github.com/buildpack/forge/engine"
+	. "github.com/buildpack/forge/engine/docker"
+	"github.com/buildpack/forge/engine/docker/archive"
 )
 
 var _ = Describe("Image", func() {
@@ -37,9 +42,13 @@ var _ = Describe("Image", func() {
 
 			progress := engine.NewImage().Build(tag, dockerfileStream)
 			naCount := 0
+			var imageID string
 			for p := range progress {
 				status, err := p.Status()
 				Expect(err).NotTo(HaveOccurred())
+				if p.Aux != nil {
+					imageID = p.Aux.ImageID
+				}
 				if status == "N/A" {
 					naCount++
 				} else {
@@ -48,6 +57,7 @@ var _ = Describe("Image", func() {
 			}
 			Expect(naCount).To(BeNumerically(">", 0))
 			Expect(naCount).To(BeNumerically("<", 20))
+			Expect(imageID).To(HavePrefix("sha256:"))
 
 			contr, err := engine.NewContainer(&eng.ContainerConfig{
 				Name:       "some-name",
@@ -126,13 +136,143 @@ var _ = Describe("Image", func() {
 		})
 	})
 
+	Describe("#BuildFrom", func() {
+		var (
+			tags       []string
+			contextDir string
+		)
+
+		BeforeEach(func() {
+			tags = nil
+			for i := 0; i < 2; i++ {
+				uuid, err := gouuid.NewV4()
+				Expect(err).NotTo(HaveOccurred())
+				tags = append(tags, fmt.Sprintf("some-image-%s", uuid))
+			}
+
+			var err error
+			contextDir, err = ioutil.TempDir("", "forge.image.test")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ioutil.WriteFile(filepath.Join(contextDir, "some-file"), []byte("some-data"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(contextDir, "some-ignored-file"), []byte("some-ignored-data"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(contextDir, ".dockerignore"), []byte("some-ignored-*\nsome.Dockerfile\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(contextDir, "some.Dockerfile"), []byte(`
+				FROM sclevine/test AS some-stage
+				ARG SOME_ARG
+				COPY . /some-dir
+				RUN echo "$SOME_ARG" > /some-arg
+
+				FROM sclevine/test AS some-other-stage
+				RUN false
+			`), 0644)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			for _, tag := range tags {
+				clearImage(tag)
+			}
+			Expect(os.RemoveAll(contextDir)).To(Succeed())
+		})
+
+		It("should build a context directory with the provided options", func() {
+			progress := engine.NewImage().BuildFrom(&eng.BuildConfig{
+				ContextDir: contextDir,
+				Dockerfile: "some.Dockerfile",
+				Tags:       tags,
+				BuildArgs:  map[string]string{"SOME_ARG": "some-value"},
+				Labels:     map[string]string{"some-label": "some-value"},
+				Target:     "some-stage",
+				NoCache:    true,
+			})
+			var imageID string
+			for p := range progress {
+				_, err := p.Status()
+				Expect(err).NotTo(HaveOccurred())
+				if p.Aux != nil {
+					imageID = p.Aux.ImageID
+				}
+			}
+			Expect(imageID).To(HavePrefix("sha256:"))
+
+			ctx := context.Background()
+			for _, tag := range tags {
+				info, _, err := client.ImageInspectWithRaw(ctx, tag)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.ID).To(Equal(imageID))
+				Expect(info.Config.Labels).To(HaveKeyWithValue("some-label", "some-value"))
+				Expect(info.Config.Labels).To(HaveKeyWithValue(eng.LabelRole, eng.RoleBuild))
+			}
+
+			contr, err := engine.NewContainer(&eng.ContainerConfig{
+				Name:       "some-name",
+				Image:      imageID,
+				Entrypoint: []string{"bash"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			defer contr.Close()
+
+			outStream, err := contr.StreamFileFrom("/some-arg")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ioutil.ReadAll(outStream)).To(Equal([]byte("some-value\n")))
+
+			outStream, err = contr.StreamFileFrom("/some-dir/some-file")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ioutil.ReadAll(outStream)).To(Equal([]byte("some-data")))
+
+			_, err = contr.StreamFileFrom("/some-dir/some-ignored-file")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should build a context tar stream", func() {
+			tar, err := archive.Tar(contextDir, nil)
+			Expect(err).NotTo(HaveOccurred())
+			progress := engine.NewImage().BuildFrom(&eng.BuildConfig{
+				Context:    tar,
+				Dockerfile: "some.Dockerfile",
+				Tags:       tags[:1],
+				Target:     "some-stage",
+			})
+			for p := range progress {
+				_, err := p.Status()
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			ctx := context.Background()
+			_, _, err = client.ImageInspectWithRaw(ctx, tags[0])
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
 	// TODO: test push/pull/delete together with random ref
 
 	Describe("#Pull", func() {
+		It("should only return an error for an invalid docker config when pulling", func() {
+			tmpDir, err := ioutil.TempDir("", "forge-docker-config")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(tmpDir)
+			Expect(ioutil.WriteFile(filepath.Join(tmpDir, "config.json"), []byte("some-invalid-json"), 0600)).To(Succeed())
+			defer os.Setenv("DOCKER_CONFIG", os.Getenv("DOCKER_CONFIG"))
+			Expect(os.Setenv("DOCKER_CONFIG", tmpDir)).To(Succeed())
+
+			engine, err := New(&eng.EngineConfig{})
+			Expect(err).NotTo(HaveOccurred())
+			defer engine.Close()
+			image := engine.NewImage()
+			Expect(image.Exists("sclevine/test")).To(BeTrue())
+
+			progress := image.Pull("sclevine/test")
+			var p eng.Progress
+			Eventually(progress).Should(Receive(&p))
+			_, err = p.Status()
+			Expect(err).To(HaveOccurred())
+			Eventually(progress).Should(BeClosed())
+		})
+
 		// TODO: consider using a new image for this test
 		It("should pull a Docker image", func() {
 			progress := engine.NewImage().Pull("sclevine/test")
 			naCount := 0
+			var messages []string
 			for p := range progress {
 				status, err := p.Status()
 				Expect(err).NotTo(HaveOccurred())
@@ -140,8 +280,12 @@ var _ = Describe("Image", func() {
 					naCount++
 				} else {
 					Expect(status).To(HaveSuffix("MB"))
+					Expect(p.ID).NotTo(BeEmpty())
+					Expect(p.Current).To(BeNumerically("<=", p.Total))
 				}
+				messages = append(messages, p.Message)
 			}
+			Expect(messages).To(ContainElement(HavePrefix("Status:")))
 			Expect(naCount).To(BeNumerically(">", 0))
 			Expect(naCount).To(BeNumerically("<", 20))
 
@@ -181,10 +325,128 @@ var _ = Describe("Image", func() {
 		})
 	})
 
+	Describe("#Inspect / #List / #Tag / #Exists", func() {
+		var tag, otherTag string
+
+		BeforeEach(func() {
+			uuid, err := gouuid.NewV4()
+			Expect(err).NotTo(HaveOccurred())
+			tag = fmt.Sprintf("some-image-%s", uuid)
+			otherTag = fmt.Sprintf("some-other-image-%s", uuid)
+
+			dockerfile := bytes.NewBufferString(`
+				FROM sclevine/test
+				ENV SOME_KEY=some-value
+				ENTRYPOINT ["some-entrypoint"]
+				LABEL some-label=some-value
+			`)
+			dockerfileStream := eng.NewStream(ioutil.NopCloser(dockerfile), int64(dockerfile.Len()))
+			for p := range engine.NewImage().Build(tag, dockerfileStream) {
+				_, err := p.Status()
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		AfterEach(func() {
+			clearImage(tag)
+			clearImage(otherTag)
+		})
+
+		It("should inspect, list and tag images", func() {
+			image := engine.NewImage()
+			Expect(image.Exists(tag)).To(BeTrue())
+			Expect(image.Exists(otherTag)).To(BeFalse())
+
+			Expect(image.Tag(tag, otherTag)).To(Succeed())
+			Expect(image.Exists(otherTag)).To(BeTrue())
+
+			info, err := image.Inspect(otherTag)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.ID).To(HavePrefix("sha256:"))
+			Expect(info.RepoTags).To(ConsistOf(tag+":latest", otherTag+":latest"))
+			Expect(info.Labels).To(HaveKeyWithValue("some-label", "some-value"))
+			Expect(info.Env).To(ContainElement("SOME_KEY=some-value"))
+			Expect(info.Entrypoint).To(Equal([]string{"some-entrypoint"}))
+			Expect(info.Created).To(BeTemporally("~", time.Now(), time.Minute))
+			Expect(info.Size).To(BeNumerically(">", 0))
+			Expect(info.Layers).NotTo(BeEmpty())
+			Expect(info.History).NotTo(BeEmpty())
+			last := info.History[len(info.History)-1]
+			Expect(last.CreatedBy).To(ContainSubstring("LABEL some-label=some-value"))
+			Expect(last.EmptyLayer).To(BeTrue())
+
+			images, err := image.List(map[string]string{"some-label": "some-value", eng.LabelRole: ""})
+			Expect(err).NotTo(HaveOccurred())
+			var ids []string
+			for _, img := range images {
+				ids = append(ids, img.ID)
+			}
+			Expect(ids).To(ContainElement(info.ID))
+
+			images, err = image.List(map[string]string{"some-label": "some-other-value"})
+			Expect(err).NotTo(HaveOccurred())
+			for _, img := range images {
+				Expect(img.ID).NotTo(Equal(info.ID))
+			}
+		})
+
+		It("should return an error when the image does not exist", func() {
+			_, err := engine.NewImage().Inspect("some-missing-image")
+			Expect(docker.IsErrNotFound(err)).To(BeTrue())
+			Expect(engine.NewImage().Tag("some-missing-image", otherTag)).NotTo(Succeed())
+		})
+	})
+
 	Describe("#Push", func() {
 		// TODO: setup test registry
 	})
 
+	Describe("#Save / #Load", func() {
+		It("should save images to a tar archive and load them back", func() {
+			uuid, err := gouuid.NewV4()
+			Expect(err).NotTo(HaveOccurred())
+			tag := fmt.Sprintf("some-image-%s", uuid)
+
+			dockerfile := bytes.NewBufferString(fmt.Sprintf("FROM sclevine/test\nLABEL some-label=%s\n", uuid))
+			dockerfileStream := eng.NewStream(ioutil.NopCloser(dockerfile), int64(dockerfile.Len()))
+			var imageID string
+			for p := range engine.NewImage().Build(tag, dockerfileStream) {
+				_, err := p.Status()
+				Expect(err).NotTo(HaveOccurred())
+				if p.Aux != nil {
+					imageID = p.Aux.ImageID
+				}
+			}
+			defer clearImage(tag)
+
+			image := engine.NewImage()
+			progress := make(chan eng.Progress, 1)
+			archive, err := image.Save(progress, tag)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(archive.Size).To(Equal(int64(-1)))
+			Expect(image.Delete(tag)).To(Succeed())
+			Expect(image.Exists(tag)).To(BeFalse())
+
+			var loaded []string
+			var output string
+			for p := range image.Load(archive) {
+				_, err := p.Status()
+				Expect(err).NotTo(HaveOccurred())
+				if p.Aux != nil {
+					loaded = append(loaded, p.Aux.ImageID)
+				}
+				output += p.Stream
+			}
+			Expect(loaded).To(Equal([]string{imageID}))
+			Expect(output).To(ContainSubstring("Loaded image: " + tag + ":latest"))
+			var last eng.Progress
+			Eventually(progress).Should(Receive(&last))
+			Expect(last.ID).To(Equal("save"))
+			Expect(last.Current).To(BeNumerically(">", 0))
+			Expect(image.Exists(tag)).To(BeTrue())
+		})
+	})
+
 	Describe("#Delete", func() {
 		It("should delete a Docker image", func() {
 			uuid, err := gouuid.NewV4()
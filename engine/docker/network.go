@@ -0,0 +1,61 @@
+package docker
+
+import (
+	"context"
+	"sort"
+
+	eng "github.com/buildpack/forge/engine"
+)
+
+func (c *container) Network() (*eng.NetworkInfo, error) {
+	return c.NetworkContext(context.Background())
+}
+
+func (c *container) NetworkContext(ctx context.Context) (*eng.NetworkInfo, error) {
+	info, err := c.docker.ContainerInspect(ctx, c.id)
+	if err != nil {
+		return nil, err
+	}
+	if mode := info.HostConfig.NetworkMode; mode.IsContainer() {
+		if info, err = c.docker.ContainerInspect(ctx, mode.ConnectedContainer()); err != nil {
+			return nil, err
+		}
+	}
+	network := &eng.NetworkInfo{IPAddresses: map[string]string{}}
+	if info.NetworkSettings == nil {
+		return network, nil
+	}
+	for name, endpoint := range info.NetworkSettings.Networks {
+		if endpoint != nil && endpoint.IPAddress != "" {
+			network.IPAddresses[name] = endpoint.IPAddress
+		}
+	}
+	for port, bindings := range info.NetworkSettings.Ports {
+		for _, binding := range bindings {
+			network.Ports = append(network.Ports, eng.PortBinding{
+				Protocol:      port.Proto(),
+				ContainerPort: port.Port(),
+				HostIP:        binding.HostIP,
+				HostPort:      binding.HostPort,
+			})
+		}
+	}
+	sort.Slice(network.Ports, func(i, j int) bool {
+		a, b := network.Ports[i], network.Ports[j]
+		if a.ContainerPort != b.ContainerPort {
+			return portLess(a.ContainerPort, b.ContainerPort)
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		return a.HostIP < b.HostIP
+	})
+	return network, nil
+}
+
+func portLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
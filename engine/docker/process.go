@@ -0,0 +1,10 @@
+// +build !windows
+
+package docker
+
+import "syscall"
+
+func processRunning(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
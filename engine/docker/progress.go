@@ -1,27 +1,15 @@
 package docker
 
-import "errors"
+import (
+	"errors"
 
-type progressMsg string
+	eng "github.com/buildpack/forge/engine"
+)
 
-func (p progressMsg) Status() (string, error) {
-	return string(p), nil
+func progressError(err error) eng.Progress {
+	return eng.Progress{Err: err}
 }
 
-type progressNA struct{}
-
-func (p progressNA) Status() (string, error) {
-	return "N/A", nil
-}
-
-type progressError struct{ error }
-
-func (p progressError) Status() (string, error) {
-	return "", p.error
-}
-
-type progressErrorString string
-
-func (p progressErrorString) Status() (string, error) {
-	return "", errors.New(string(p))
+func progressErrorString(msg string) eng.Progress {
+	return eng.Progress{Err: errors.New(msg)}
 }
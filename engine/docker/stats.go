@@ -0,0 +1,96 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+
+	eng "github.com/buildpack/forge/engine"
+)
+
+func (c *container) Stats() <-chan eng.Stats {
+	return c.StatsContext(context.Background())
+}
+
+func (c *container) StatsContext(ctx context.Context) <-chan eng.Stats {
+	stats := make(chan eng.Stats)
+	go func() {
+		defer close(stats)
+		ctx, cancel := withExit(ctx, c.exit)
+		defer cancel()
+
+		fail := func(err error) {
+			if err == io.EOF || ctx.Err() != nil {
+				return
+			}
+			select {
+			case stats <- eng.Stats{Err: err}:
+			case <-ctx.Done():
+			}
+		}
+
+		response, err := c.docker.ContainerStats(ctx, c.id, true)
+		if err != nil {
+			fail(err)
+			return
+		}
+		defer response.Body.Close()
+
+		decoder := json.NewDecoder(response.Body)
+		for {
+			var statsJSON types.StatsJSON
+			if err := decoder.Decode(&statsJSON); err != nil {
+				fail(err)
+				return
+			}
+			select {
+			case stats <- convertStats(&statsJSON):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return stats
+}
+
+func convertStats(s *types.StatsJSON) eng.Stats {
+	stats := eng.Stats{
+		Time:        s.Read,
+		CPUPercent:  cpuPercent(s),
+		MemoryUsage: s.MemoryStats.Usage - s.MemoryStats.Stats["cache"],
+		MemoryLimit: s.MemoryStats.Limit,
+		PIDs:        s.PidsStats.Current,
+	}
+	if s.MemoryStats.Stats["cache"] > s.MemoryStats.Usage {
+		stats.MemoryUsage = 0
+	}
+	for _, network := range s.Networks {
+		stats.NetworkRx += network.RxBytes
+		stats.NetworkTx += network.TxBytes
+	}
+	for _, entry := range s.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			stats.BlockRead += entry.Value
+		case "write":
+			stats.BlockWrite += entry.Value
+		}
+	}
+	return stats
+}
+
+func cpuPercent(s *types.StatsJSON) float64 {
+	cpuDelta := float64(s.CPUStats.CPUUsage.TotalUsage) - float64(s.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(s.CPUStats.SystemUsage) - float64(s.PreCPUStats.SystemUsage)
+	cpus := float64(s.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(s.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+	return cpuDelta / systemDelta * cpus * 100
+}
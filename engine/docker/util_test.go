@@ -5,11 +5,14 @@ import (
 	"io"
 	"net"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/docker/docker/api/types"
 	docker "github.com/docker/docker/client"
 	. "github.com/onsi/gomega"
+
+	eng "github.com/buildpack/forge/engine"
 )
 
 func containerFound(id string) bool {
@@ -78,3 +81,30 @@ func (c *closeTester) Close() (err error) {
 	c.closed = true
 	return c.err
 }
+
+type testSink struct {
+	records []eng.LogRecord
+	mutex   sync.Mutex
+}
+
+func (t *testSink) Log(record *eng.LogRecord) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.records = append(t.records, *record)
+	return nil
+}
+
+func (t *testSink) Records() []eng.LogRecord {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]eng.LogRecord(nil), t.records...)
+}
+
+func findResource(resources []eng.Resource, id string) (eng.Resource, bool) {
+	for _, r := range resources {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return eng.Resource{}, false
+}
@@ -0,0 +1,24 @@
+package engine
+
+import "time"
+
+type EventAction string
+
+const (
+	EventCreate       EventAction = "create"
+	EventStart        EventAction = "start"
+	EventDie          EventAction = "die"
+	EventOOM          EventAction = "oom"
+	EventHealthStatus EventAction = "health_status"
+	EventDestroy      EventAction = "destroy"
+)
+
+type Event struct {
+	Action      EventAction
+	ContainerID string
+	Name        string
+	Image       string
+	Time        time.Time
+	ExitCode    int64  // die only
+	Health      string // health_status only
+}
@@ -0,0 +1,749 @@
+package fake
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	gopath "path"
+	"sync"
+	"time"
+
+	eng "github.com/buildpack/forge/engine"
+)
+
+type Container struct {
+	engine *Engine
+	id     string
+	name   string
+	config *eng.ContainerConfig
+	labels map[string]string
+	ip     string
+	ports  []eng.PortBinding
+	exit   <-chan struct{}
+	check  <-chan time.Time
+
+	mutex   sync.Mutex
+	files   fileSystem
+	health  string
+	stats   eng.Stats
+	removed bool
+	paused  bool
+	procs   map[*process]struct{}
+}
+
+type process struct {
+	exitCode int64
+	exit     chan struct{}
+	done     chan struct{}
+	status   chan int64
+	signals  chan string
+	once     sync.Once
+}
+
+func (e *Engine) NewContainer(config *eng.ContainerConfig) (eng.Container, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	img, ok := e.findImage(config.Image)
+	if !ok {
+		return nil, fmt.Errorf("Error: No such image: %s", config.Image)
+	}
+	check := config.Check
+	if check == nil {
+		check = time.NewTicker(time.Second).C
+	}
+	exit := config.Exit
+	if exit == nil {
+		exit = e.Exit
+	}
+	ports, err := e.assignPorts(config)
+	if err != nil {
+		return nil, err
+	}
+	id := e.newID("")
+	contr := &Container{
+		engine: e,
+		id:     id,
+		name:   fmt.Sprintf("%s-%s", config.Name, id[:12]),
+		config: config,
+		labels: e.labels(config.Role, config.AppName),
+		ip:     fmt.Sprintf("172.17.%d.%d", e.lastID/254%256, e.lastID%254+1),
+		ports:  ports,
+		exit:   exit,
+		check:  check,
+		files:  img.files.clone(),
+		health: "none",
+		procs:  map[*process]struct{}{},
+	}
+	e.containers[id] = contr
+	e.publish(contr.event(eng.EventCreate))
+	return contr, nil
+}
+
+func (c *Container) ID() string {
+	return c.id
+}
+
+func (c *Container) Name() string {
+	return c.name
+}
+
+func (c *Container) Config() *eng.ContainerConfig {
+	return c.config
+}
+
+func (c *Container) Labels() map[string]string {
+	return c.labels
+}
+
+func (c *Container) Running() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.procs) > 0
+}
+
+func (c *Container) Paused() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.paused
+}
+
+func (c *Container) Removed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.removed
+}
+
+func (c *Container) SetHealth(status string) {
+	c.mutex.Lock()
+	c.health = status
+	c.mutex.Unlock()
+
+	event := c.event(eng.EventHealthStatus)
+	event.Health = status
+	c.engine.publish(event)
+}
+
+func (c *Container) SetStats(stats eng.Stats) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stats = stats
+}
+
+func (c *Container) ReadFile(path string) ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.files.readFile(path)
+}
+
+func (c *Container) WriteFile(path string, data []byte, mode os.FileMode) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.files.writeFile(path, data, mode)
+}
+
+func (c *Container) Close() error {
+	c.mutex.Lock()
+	if c.removed {
+		c.mutex.Unlock()
+		return c.errNotFound()
+	}
+	c.removed = true
+	procs := c.procs
+	c.procs = map[*process]struct{}{}
+	c.mutex.Unlock()
+
+	for proc := range procs {
+		proc.kill()
+	}
+	c.engine.mutex.Lock()
+	delete(c.engine.containers, c.id)
+	c.engine.mutex.Unlock()
+	c.engine.publish(c.event(eng.EventDestroy))
+	return nil
+}
+
+func (c *Container) CloseAfterStream(stream *eng.Stream) error {
+	if stream == nil || stream.ReadCloser == nil {
+		return c.Close()
+	}
+	stream.ReadCloser = &closeWrapper{
+		ReadCloser: stream.ReadCloser,
+		After:      c.Close,
+	}
+	return nil
+}
+
+func (c *Container) Background() error {
+	if err := c.checkExists(); err != nil {
+		return err
+	}
+	c.spawnMain(&bytes.Buffer{}, ioutil.Discard, ioutil.Discard)
+	return nil
+}
+
+func (c *Container) Stop(timeout time.Duration) error {
+	return c.StopContext(context.Background(), timeout)
+}
+
+// StopContext sends SIGTERM to running scripts and kills those that have not returned after timeout.
+func (c *Container) StopContext(ctx context.Context, timeout time.Duration) error {
+	procs, err := c.running()
+	if err != nil {
+		return err
+	}
+	for _, proc := range procs {
+		select {
+		case proc.signals <- "SIGTERM":
+		default:
+		}
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+wait:
+	for _, proc := range procs {
+		select {
+		case <-proc.done:
+		case <-deadline.C:
+			break wait
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	for _, proc := range procs {
+		proc.kill()
+	}
+	for _, proc := range procs {
+		select {
+		case <-proc.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (c *Container) Kill(signal string) error {
+	return c.KillContext(context.Background(), signal)
+}
+
+func (c *Container) KillContext(ctx context.Context, signal string) error {
+	procs, err := c.running()
+	if err != nil {
+		return err
+	}
+	if len(procs) == 0 {
+		return fmt.Errorf("Error response from daemon: Container %s is not running", c.id)
+	}
+	for _, proc := range procs {
+		switch signal {
+		case "", "KILL", "SIGKILL", "9":
+			proc.kill()
+		default:
+			select {
+			case proc.signals <- signal:
+			default:
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Container) Pause() error {
+	return c.PauseContext(context.Background())
+}
+
+func (c *Container) PauseContext(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.removed {
+		return c.errNotFound()
+	}
+	if len(c.procs) == 0 {
+		return fmt.Errorf("Error response from daemon: Container %s is not running", c.id)
+	}
+	c.paused = true
+	return nil
+}
+
+func (c *Container) Unpause() error {
+	return c.UnpauseContext(context.Background())
+}
+
+func (c *Container) UnpauseContext(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.removed {
+		return c.errNotFound()
+	}
+	if !c.paused {
+		return fmt.Errorf("Error response from daemon: Container %s is not paused", c.id)
+	}
+	c.paused = false
+	return nil
+}
+
+func (c *Container) Start(logPrefix string, logs io.Writer, restart <-chan time.Time) (status int64, err error) {
+	return c.StartContext(context.Background(), logPrefix, logs, restart)
+}
+
+func (c *Container) StartContext(ctx context.Context, logPrefix string, logs io.Writer, restart <-chan time.Time) (status int64, err error) {
+	if err := c.checkExists(); err != nil {
+		return 0, err
+	}
+	var stdout, stderr io.Writer
+	if c.config.LogSink != nil {
+		stdout, stderr = c.logWriter(eng.Stdout), c.logWriter(eng.Stderr)
+	} else {
+		out := &prefixWriter{w: logs, prefix: logPrefix}
+		stdout, stderr = out, out
+	}
+	for {
+		proc := c.spawnMain(&bytes.Buffer{}, stdout, stderr)
+		if restart == nil {
+			select {
+			case status := <-proc.status:
+				return status, nil
+			case <-c.exit:
+				c.stop(proc)
+				return 128, nil
+			case <-ctx.Done():
+				c.stop(proc)
+				return 128, nil
+			}
+		}
+		select {
+		case <-restart:
+			c.stop(proc)
+		case <-c.exit:
+			c.stop(proc)
+			return 128, nil
+		case <-ctx.Done():
+			c.stop(proc)
+			return 128, nil
+		}
+	}
+}
+
+func (c *Container) Shell(tty eng.TTY, shell ...string) (err error) {
+	return c.ShellContext(context.Background(), tty, shell...)
+}
+
+func (c *Container) ShellContext(ctx context.Context, tty eng.TTY, shell ...string) (err error) {
+	if err := c.checkExists(); err != nil {
+		return err
+	}
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+	proc := c.spawn(shell, inReader, outWriter, outWriter)
+	go func() {
+		<-proc.status
+		outWriter.Close()
+	}()
+	defer c.stop(proc)
+	defer inWriter.Close()
+	defer outReader.Close()
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- tty.Run(outReader, inWriter, func(h, w uint16) error {
+			return c.checkExists()
+		})
+	}()
+	select {
+	case err := <-runErr:
+		return err
+	case <-c.exit:
+		return nil
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+func (c *Container) Exec(config *eng.ExecConfig) (status int64, err error) {
+	return c.ExecContext(context.Background(), config)
+}
+
+func (c *Container) ExecContext(ctx context.Context, config *eng.ExecConfig) (status int64, err error) {
+	if err := c.checkExists(); err != nil {
+		return 0, err
+	}
+	if !c.Running() {
+		return 0, fmt.Errorf("Error response from daemon: Container %s is not running", c.id)
+	}
+	stdin, stdout, stderr := config.Stdin, config.Stdout, config.Stderr
+	if stdin == nil {
+		stdin = &bytes.Buffer{}
+	}
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+	proc := c.spawn(config.Cmd, stdin, stdout, stderr)
+	select {
+	case status := <-proc.status:
+		return status, nil
+	case <-c.exit:
+		c.stop(proc)
+		return 128, nil
+	case <-ctx.Done():
+		c.stop(proc)
+		return 128, nil
+	}
+}
+
+func (c *Container) HealthCheck() <-chan string {
+	return c.HealthCheckContext(context.Background())
+}
+
+func (c *Container) HealthCheckContext(ctx context.Context) <-chan string {
+	status := make(chan string)
+	go func() {
+		for {
+			select {
+			case <-c.exit:
+				return
+			case <-ctx.Done():
+				return
+			case <-c.check:
+				c.mutex.Lock()
+				health := c.health
+				c.mutex.Unlock()
+				status <- health
+			}
+		}
+	}()
+	return status
+}
+
+func (c *Container) Stats() <-chan eng.Stats {
+	return c.StatsContext(context.Background())
+}
+
+func (c *Container) StatsContext(ctx context.Context) <-chan eng.Stats {
+	stats := make(chan eng.Stats)
+	go func() {
+		defer close(stats)
+		for {
+			select {
+			case <-c.exit:
+				return
+			case <-ctx.Done():
+				return
+			case t := <-c.check:
+				c.mutex.Lock()
+				sample, removed := c.stats, c.removed
+				c.mutex.Unlock()
+				if removed {
+					return
+				}
+				if sample.Time.IsZero() {
+					sample.Time = t
+				}
+				select {
+				case stats <- sample:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return stats
+}
+
+func (c *Container) Commit(ref string) (imageID string, err error) {
+	return c.CommitContext(context.Background(), ref)
+}
+
+func (c *Container) CommitContext(ctx context.Context, ref string) (imageID string, err error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if err := c.checkExists(); err != nil {
+		return "", err
+	}
+	c.mutex.Lock()
+	files := c.files.clone()
+	c.mutex.Unlock()
+
+	c.engine.mutex.Lock()
+	defer c.engine.mutex.Unlock()
+	labels := map[string]string{}
+	if img, ok := c.engine.findImage(c.config.Image); ok {
+		for k, v := range img.labels {
+			labels[k] = v
+		}
+	}
+	for k, v := range c.engine.labels(c.config.Role, c.config.AppName) {
+		labels[k] = v
+	}
+	return c.engine.addImage(ref, files, c.config, labels), nil
+}
+
+func (c *Container) UploadTarTo(tar io.Reader, path string) error {
+	return c.UploadTarToContext(context.Background(), tar, path)
+}
+
+func (c *Container) UploadTarToContext(ctx context.Context, tar io.Reader, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.removed {
+		return c.errNotFound()
+	}
+	if !c.files.isDir(path) {
+		return c.errNoFile(path)
+	}
+	if tar == nil {
+		return errors.New("missing tar stream")
+	}
+	return c.files.readTar(tar, path)
+}
+
+func (c *Container) StreamFileTo(stream eng.Stream, path string) error {
+	data := &bytes.Buffer{}
+	if _, err := io.CopyN(data, stream, stream.Remaining()); err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	err := c.files.writeFile(path, data.Bytes(), 0755)
+	c.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	return stream.Close()
+}
+
+func (c *Container) StreamTarTo(stream eng.Stream, path string) error {
+	if err := c.UploadTarTo(stream, path); err != nil {
+		return err
+	}
+	return stream.Close()
+}
+
+func (c *Container) StreamFileFrom(path string) (eng.Stream, error) {
+	return c.StreamFileFromContext(context.Background(), path)
+}
+
+func (c *Container) StreamFileFromContext(ctx context.Context, path string) (eng.Stream, error) {
+	if err := ctx.Err(); err != nil {
+		return eng.Stream{}, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.removed {
+		return eng.Stream{}, c.errNotFound()
+	}
+	if _, ok := c.files[cleanPath(path)]; !ok {
+		return eng.Stream{}, c.errNoFile(path)
+	}
+	data, err := c.files.readFile(path)
+	if err != nil {
+		return eng.Stream{}, err
+	}
+	return eng.NewStream(ioutil.NopCloser(bytes.NewReader(data)), int64(len(data))), nil
+}
+
+func (c *Container) StreamTarFrom(path string) (eng.Stream, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.removed {
+		return eng.Stream{}, c.errNotFound()
+	}
+	if !c.files.isDir(path) {
+		return eng.Stream{}, c.errNoFile(path)
+	}
+	tar, err := c.files.writeTar(path)
+	if err != nil {
+		return eng.Stream{}, err
+	}
+	return eng.NewStream(ioutil.NopCloser(bytes.NewReader(tar)), int64(len(tar))), nil
+}
+
+func (c *Container) Mkdir(path string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.removed {
+		return c.errNotFound()
+	}
+	if !c.files.isDir(gopath.Dir(path)) {
+		return c.errNoFile(gopath.Dir(path))
+	}
+	return c.files.mkdirAll(path, 0755)
+}
+
+func (c *Container) args() []string {
+	return append(append([]string(nil), c.config.Entrypoint...), c.config.Cmd...)
+}
+
+func (c *Container) spawnMain(stdin io.Reader, stdout, stderr io.Writer) *process {
+	c.engine.publish(c.event(eng.EventStart))
+	proc := c.spawn(c.args(), stdin, stdout, stderr)
+	go func() {
+		<-proc.done
+		event := c.event(eng.EventDie)
+		event.ExitCode = proc.exitCode
+		c.engine.publish(event)
+	}()
+	return proc
+}
+
+func (c *Container) spawn(args []string, stdin io.Reader, stdout, stderr io.Writer) *process {
+	proc := &process{
+		exit:    make(chan struct{}),
+		done:    make(chan struct{}),
+		status:  make(chan int64, 1),
+		signals: make(chan string, 16),
+	}
+	c.mutex.Lock()
+	c.procs[proc] = struct{}{}
+	c.mutex.Unlock()
+
+	script := c.engine.script(c.config.Name)
+	go func() {
+		status := script(&Process{
+			Container: c,
+			Args:      args,
+			Stdin:     stdin,
+			Stdout:    stdout,
+			Stderr:    stderr,
+			Signals:   proc.signals,
+			Exit:      proc.exit,
+		})
+		c.mutex.Lock()
+		delete(c.procs, proc)
+		c.mutex.Unlock()
+		proc.exitCode = status
+		proc.status <- status
+		close(proc.done)
+	}()
+	return proc
+}
+
+func (c *Container) stop(proc *process) {
+	proc.kill()
+	<-proc.done
+}
+
+func (p *process) kill() {
+	p.once.Do(func() { close(p.exit) })
+}
+
+func (c *Container) running() ([]*process, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.removed {
+		return nil, c.errNotFound()
+	}
+	var procs []*process
+	for proc := range c.procs {
+		procs = append(procs, proc)
+	}
+	return procs, nil
+}
+
+func (c *Container) checkExists() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.removed {
+		return c.errNotFound()
+	}
+	return nil
+}
+
+func (c *Container) errNotFound() error {
+	return fmt.Errorf("Error: No such container: %s", c.id)
+}
+
+func (c *Container) errNoFile(path string) error {
+	return fmt.Errorf("Error: Could not find the file %s in container %s", path, c.id)
+}
+
+type prefixWriter struct {
+	w       io.Writer
+	prefix  string
+	mutex   sync.Mutex
+	midLine bool
+}
+
+func (p *prefixWriter) Write(b []byte) (n int, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for len(b) > 0 {
+		if !p.midLine {
+			if _, err := io.WriteString(p.w, p.prefix); err != nil {
+				return n, err
+			}
+		}
+		line := b
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			line = b[:i+1]
+		}
+		m, err := p.w.Write(line)
+		n += m
+		if err != nil {
+			return n, err
+		}
+		p.midLine = line[len(line)-1] != '\n'
+		b = b[len(line):]
+	}
+	return n, nil
+}
+
+func (c *Container) logWriter(stream eng.LogStream) *logWriter {
+	return &logWriter{
+		sink: c.config.LogSink,
+		record: eng.LogRecord{
+			Stream:      stream,
+			ContainerID: c.id,
+			AppName:     c.config.AppName,
+		},
+	}
+}
+
+type logWriter struct {
+	sink   eng.LogSink
+	record eng.LogRecord
+	buf    bytes.Buffer
+	mutex  sync.Mutex
+}
+
+func (l *logWriter) Write(b []byte) (n int, err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.buf.Write(b)
+	for {
+		i := bytes.IndexByte(l.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		record := l.record
+		record.Time = time.Now()
+		record.Message = string(l.buf.Next(i + 1)[:i])
+		if err := l.sink.Log(&record); err != nil {
+			return len(b), err
+		}
+	}
+}
+
+type closeWrapper struct {
+	io.ReadCloser
+	After func() error
+}
+
+func (c *closeWrapper) Close() (err error) {
+	defer func() {
+		if afterErr := c.After(); err == nil {
+			err = afterErr
+		}
+	}()
+	return c.ReadCloser.Close()
+}
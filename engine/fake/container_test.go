@@ -0,0 +1,406 @@
+package fake_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+
+	eng "github.com/buildpack/forge/engine"
+	. "github.com/buildpack/forge/engine/fake"
+	"github.com/buildpack/forge/testutil"
+)
+
+var _ = Describe("Container", func() {
+	var (
+		engine *Engine
+		contr  eng.Container
+		exit   chan struct{}
+	)
+
+	BeforeEach(func() {
+		exit = make(chan struct{})
+		engine = New(&eng.EngineConfig{Exit: exit})
+		engine.AddImage("some-image", map[string]string{"/some-dir/some-file": "some-data"})
+
+		var err error
+		contr, err = engine.NewContainer(&eng.ContainerConfig{
+			Name:       "some-name",
+			Image:      "some-image",
+			Entrypoint: []string{"some-entrypoint"},
+			Cmd:        []string{"some-arg"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe(".NewContainer", func() {
+		It("should copy the image filesystem into the container", func() {
+			Expect(contr.(*Container).ReadFile("/some-dir/some-file")).To(Equal([]byte("some-data")))
+			Expect(engine.Containers()).To(Equal([]*Container{contr.(*Container)}))
+		})
+
+		It("should return an error when the image does not exist", func() {
+			_, err := engine.NewContainer(&eng.ContainerConfig{Image: "some-bad-image"})
+			Expect(err).To(MatchError("Error: No such image: some-bad-image"))
+		})
+	})
+
+	Describe("#Close", func() {
+		It("should remove the container", func() {
+			Expect(contr.Close()).To(Succeed())
+			Expect(engine.Containers()).To(BeEmpty())
+			Expect(contr.Close()).To(MatchError(ContainSubstring("No such container")))
+		})
+	})
+
+	Describe("#Start", func() {
+		It("should run the script for the container and return its status", func() {
+			engine.Scripts["some-name"] = func(proc *Process) int64 {
+				defer GinkgoRecover()
+				Expect(proc.Args).To(Equal([]string{"some-entrypoint", "some-arg"}))
+				fmt.Fprint(proc.Stdout, "some-stdout\nsome-")
+				fmt.Fprint(proc.Stderr, "stderr\n")
+				Expect(proc.Container.WriteFile("/out/some-file", []byte("some-output"), 0644)).To(Succeed())
+				return 3
+			}
+			logs := gbytes.NewBuffer()
+			Expect(contr.Start("[some-prefix] ", logs, nil)).To(Equal(int64(3)))
+			Expect(string(logs.Contents())).To(Equal("[some-prefix] some-stdout\n[some-prefix] some-stderr\n"))
+			Expect(contr.(*Container).ReadFile("/out/some-file")).To(Equal([]byte("some-output")))
+		})
+
+		It("should send structured log records to the configured sink", func() {
+			sink := &testSink{}
+			contr, err := engine.NewContainer(&eng.ContainerConfig{
+				Name:    "some-name",
+				AppName: "some-app",
+				Image:   "some-image",
+				LogSink: sink,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			engine.Scripts["some-name"] = func(proc *Process) int64 {
+				fmt.Fprint(proc.Stdout, "some-stdout\n")
+				fmt.Fprint(proc.Stderr, "some-stderr\n")
+				return 0
+			}
+			Expect(contr.Start("", ioutil.Discard, nil)).To(Equal(int64(0)))
+			Expect(sink.records).To(HaveLen(2))
+			Expect(sink.records[0].Stream).To(Equal(eng.Stdout))
+			Expect(sink.records[0].Message).To(Equal("some-stdout"))
+			Expect(sink.records[0].AppName).To(Equal("some-app"))
+			Expect(sink.records[0].ContainerID).To(Equal(contr.ID()))
+			Expect(sink.records[1].Stream).To(Equal(eng.Stderr))
+			Expect(sink.records[1].Message).To(Equal("some-stderr"))
+		})
+
+		It("should restart the script until signaled to exit then return status 128", func() {
+			engine.Scripts["some-name"] = func(proc *Process) int64 {
+				fmt.Fprintln(proc.Stdout, "some-logs")
+				<-proc.Exit
+				return 0
+			}
+			wait := testutil.Wait(2)
+			defer wait()
+
+			restart := make(chan time.Time)
+			logs := gbytes.NewBuffer()
+			go func() {
+				defer wait()
+				defer GinkgoRecover()
+				Expect(contr.Start("", logs, restart)).To(Equal(int64(128)))
+			}()
+			Eventually(logs).Should(gbytes.Say("some-logs"))
+			restart <- time.Time{}
+			Eventually(logs).Should(gbytes.Say("some-logs"))
+			close(exit)
+		})
+
+		It("should return status 128 when the context is canceled", func() {
+			engine.Scripts["some-name"] = func(proc *Process) int64 {
+				<-proc.Exit
+				return 0
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			Expect(contr.StartContext(ctx, "", ioutil.Discard, nil)).To(Equal(int64(128)))
+		})
+
+		It("should return an error when the container has been removed", func() {
+			Expect(contr.Close()).To(Succeed())
+			_, err := contr.Start("", ioutil.Discard, nil)
+			Expect(err).To(MatchError(ContainSubstring("No such container")))
+		})
+	})
+
+	Describe("#Exec", func() {
+		It("should run the script with the provided command and separate output streams", func() {
+			engine.Scripts["some-name"] = func(proc *Process) int64 {
+				if proc.Args[0] == "some-entrypoint" {
+					<-proc.Exit
+					return 0
+				}
+				defer GinkgoRecover()
+				Expect(proc.Args).To(Equal([]string{"some-command"}))
+				Expect(ioutil.ReadAll(proc.Stdin)).To(Equal([]byte("some-stdin")))
+				fmt.Fprint(proc.Stdout, "some-stdout")
+				fmt.Fprint(proc.Stderr, "some-stderr")
+				return 3
+			}
+			Expect(contr.Background()).To(Succeed())
+			defer contr.Close()
+
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			Expect(contr.Exec(&eng.ExecConfig{
+				Cmd:    []string{"some-command"},
+				Stdin:  bytes.NewBufferString("some-stdin"),
+				Stdout: stdout,
+				Stderr: stderr,
+			})).To(Equal(int64(3)))
+			Expect(stdout.String()).To(Equal("some-stdout"))
+			Expect(stderr.String()).To(Equal("some-stderr"))
+		})
+
+		It("should return an error when the container is not running", func() {
+			_, err := contr.Exec(&eng.ExecConfig{Cmd: []string{"some-command"}})
+			Expect(err).To(MatchError(ContainSubstring("is not running")))
+		})
+	})
+
+	Describe("#Stop / #Kill", func() {
+		It("should deliver signals to running scripts", func() {
+			engine.Scripts["some-name"] = func(proc *Process) int64 {
+				for {
+					select {
+					case signal := <-proc.Signals:
+						fmt.Fprintln(proc.Stdout, signal)
+					case <-proc.Exit:
+						return 3
+					}
+				}
+			}
+			wait := testutil.Wait(2)
+			defer wait()
+
+			logs := gbytes.NewBuffer()
+			go func() {
+				defer wait()
+				defer GinkgoRecover()
+				Expect(contr.Start("", logs, nil)).To(Equal(int64(3)))
+			}()
+			Eventually(contr.(*Container).Running).Should(BeTrue())
+			Expect(contr.Kill("SIGUSR1")).To(Succeed())
+			Eventually(logs).Should(gbytes.Say("SIGUSR1"))
+			Expect(contr.Stop(100 * time.Millisecond)).To(Succeed())
+			Expect(logs).To(gbytes.Say("SIGTERM"))
+			Expect(contr.(*Container).Running()).To(BeFalse())
+		})
+
+		It("should let scripts exit gracefully on SIGTERM before the timeout", func() {
+			engine.Scripts["some-name"] = func(proc *Process) int64 {
+				select {
+				case <-proc.Signals:
+					return 0
+				case <-proc.Exit:
+					return 137
+				}
+			}
+			wait := testutil.Wait(2)
+			defer wait()
+
+			go func() {
+				defer wait()
+				defer GinkgoRecover()
+				Expect(contr.Start("", ioutil.Discard, nil)).To(Equal(int64(0)))
+			}()
+			Eventually(contr.(*Container).Running).Should(BeTrue())
+			Expect(contr.Stop(time.Minute)).To(Succeed())
+			Expect(contr.(*Container).Running()).To(BeFalse())
+		})
+	})
+
+	Describe("#Network", func() {
+		It("should report assigned host ports while the container runs", func() {
+			netContr, err := engine.NewContainer(&eng.ContainerConfig{
+				Name:     "some-network",
+				Image:    "some-image",
+				Port:     "8080",
+				HostIP:   "127.0.0.1",
+				HostPort: "400",
+				Ports: []eng.PortBinding{
+					{Protocol: "udp", ContainerPort: "9000-9001"},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			appContr, err := engine.NewContainer(&eng.ContainerConfig{
+				Name:         "some-app",
+				Image:        "some-image",
+				NetContainer: netContr.ID(),
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			network, err := appContr.Network()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(network.Ports).To(BeEmpty())
+
+			engine.Scripts["some-network"] = func(proc *Process) int64 {
+				<-proc.Exit
+				return 0
+			}
+			Expect(netContr.Background()).To(Succeed())
+			network, err = appContr.Network()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(network.IPAddresses).To(HaveKey("bridge"))
+			Expect(network.Ports).To(Equal([]eng.PortBinding{
+				{Protocol: "tcp", ContainerPort: "8080", HostIP: "127.0.0.1", HostPort: "400"},
+				{Protocol: "udp", ContainerPort: "9000", HostIP: "0.0.0.0", HostPort: "32768"},
+				{Protocol: "udp", ContainerPort: "9001", HostIP: "0.0.0.0", HostPort: "32769"},
+			}))
+			close(exit)
+		})
+	})
+
+	Describe("#Stats", func() {
+		It("should report the current stats on each check", func() {
+			check := make(chan time.Time)
+			contr, err := engine.NewContainer(&eng.ContainerConfig{
+				Name:  "some-name",
+				Image: "some-image",
+				Check: check,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			contr.(*Container).SetStats(eng.Stats{MemoryUsage: 100, MemoryLimit: 200})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			stats := contr.StatsContext(ctx)
+			check <- time.Unix(100, 0)
+			Expect(<-stats).To(Equal(eng.Stats{
+				Time:        time.Unix(100, 0),
+				MemoryUsage: 100,
+				MemoryLimit: 200,
+			}))
+			cancel()
+			Eventually(stats).Should(BeClosed())
+		})
+	})
+
+	Describe("#Commit", func() {
+		It("should create an image from the container filesystem", func() {
+			Expect(contr.(*Container).WriteFile("/some-path", []byte("some-data"), 0644)).To(Succeed())
+			id, err := contr.Commit("some-ref")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(engine.HasImage(id)).To(BeTrue())
+			Expect(engine.ImageFile("some-ref:latest", "/some-path")).To(Equal([]byte("some-data")))
+		})
+
+		It("should keep the labels of the image the container was created from", func() {
+			app, err := engine.NewContainer(&eng.ContainerConfig{
+				Name:    "some-app-name",
+				Image:   "some-image",
+				Role:    eng.RoleStaging,
+				AppName: "some-app",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = app.Commit("some-app-ref")
+			Expect(err).NotTo(HaveOccurred())
+
+			other, err := engine.NewContainer(&eng.ContainerConfig{Name: "some-other-name", Image: "some-app-ref"})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = other.Commit("some-ref")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(engine.ImageLabels("some-ref")).To(HaveKeyWithValue(eng.LabelRole, eng.RoleStaging))
+			Expect(engine.ImageLabels("some-ref")).To(HaveKeyWithValue(eng.LabelApp, "some-app"))
+			Expect(engine.ImageLabels("some-ref")).To(HaveKeyWithValue(eng.LabelSession, "some-session"))
+		})
+	})
+
+	Describe("#UploadTarTo / #StreamTarFrom", func() {
+		It("should copy a tarball into and out of the container", func() {
+			tarBuffer := &bytes.Buffer{}
+			tarIn := tar.NewWriter(tarBuffer)
+			Expect(tarIn.WriteHeader(&tar.Header{Name: "some-file-1", Size: 11, Mode: 0755})).To(Succeed())
+			Expect(tarIn.Write([]byte("some-data-1"))).To(Equal(11))
+			Expect(tarIn.Close()).To(Succeed())
+			Expect(contr.UploadTarTo(tarBuffer, "/some-dir")).To(Succeed())
+
+			tarResult, err := contr.StreamTarFrom("/some-dir")
+			Expect(err).NotTo(HaveOccurred())
+			defer tarResult.Close()
+			tarOut := tar.NewReader(tarResult)
+
+			header1, err := tarOut.Next()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(header1.Name).To(Equal("./"))
+
+			header2, err := tarOut.Next()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(header2.Name).To(Equal("./some-file"))
+
+			header3, err := tarOut.Next()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(header3.Name).To(Equal("./some-file-1"))
+			Expect(header3.Mode).To(Equal(int64(0100755)))
+			Expect(ioutil.ReadAll(tarOut)).To(Equal([]byte("some-data-1")))
+
+			_, err = tarOut.Next()
+			Expect(err).To(Equal(io.EOF))
+		})
+
+		It("should return an error if the destination does not exist", func() {
+			err := contr.UploadTarTo(&bytes.Buffer{}, "/some-bad-path")
+			Expect(err).To(MatchError(ContainSubstring("some-bad-path")))
+		})
+	})
+
+	Describe("#StreamFileTo / #StreamFileFrom", func() {
+		It("should copy the stream into the container and close it", func() {
+			inStream := eng.NewStream(ioutil.NopCloser(bytes.NewBufferString("some-data")), 9)
+			Expect(contr.StreamFileTo(inStream, "/some-path/some-file")).To(Succeed())
+
+			outStream, err := contr.StreamFileFrom("/some-path/some-file")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ioutil.ReadAll(outStream)).To(Equal([]byte("some-data")))
+			Expect(outStream.Size).To(Equal(int64(9)))
+		})
+
+		It("should copy the remainder of a partially read stream", func() {
+			inStream := eng.NewStream(ioutil.NopCloser(bytes.NewBufferString("some-data")), 9)
+			Expect(inStream.Read(make([]byte, 5))).To(Equal(5))
+			Expect(contr.StreamFileTo(inStream, "/some-file")).To(Succeed())
+			Expect(contr.(*Container).ReadFile("/some-file")).To(Equal([]byte("data")))
+		})
+
+		It("should return an error if the stream is too short", func() {
+			inStream := eng.NewStream(ioutil.NopCloser(bytes.NewBufferString("some-data")), 100)
+			Expect(contr.StreamFileTo(inStream, "/some-file")).To(MatchError("EOF"))
+		})
+	})
+
+	Describe("#Mkdir", func() {
+		It("should create a directory in the container", func() {
+			Expect(contr.Mkdir("/some-dir/some-subdir")).To(Succeed())
+			_, err := contr.(*Container).ReadFile("/some-dir/some-subdir")
+			Expect(err).To(MatchError(ContainSubstring("is a directory")))
+		})
+
+		It("should return an error if the parent does not exist", func() {
+			err := contr.Mkdir("/some-bad-path/some-dir")
+			Expect(err).To(MatchError(ContainSubstring("some-bad-path")))
+		})
+	})
+})
+
+type testSink struct {
+	records []eng.LogRecord
+}
+
+func (t *testSink) Log(record *eng.LogRecord) error {
+	t.records = append(t.records, *record)
+	return nil
+}
@@ -0,0 +1,212 @@
+package fake
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	eng "github.com/buildpack/forge/engine"
+)
+
+type Engine struct {
+	Scripts       map[string]Script
+	DefaultScript Script
+	Exit          <-chan struct{}
+	Session       string
+
+	mutex      sync.Mutex
+	lastID     int
+	lastPort   int
+	closed     bool
+	containers map[string]*Container
+	images     map[string]*imageData
+	layers     map[string][]byte // diff ID to layer tarball
+	refs       map[string]string
+	pushed     map[string]eng.RegistryCreds
+
+	subsMutex sync.Mutex
+	subs      map[*subscriber]struct{}
+}
+
+type Script func(proc *Process) (status int64)
+
+type Process struct {
+	Container *Container
+	Args      []string
+	Stdin     io.Reader
+	Stdout    io.Writer
+	Stderr    io.Writer
+	Signals   <-chan string
+	Exit      <-chan struct{}
+}
+
+type imageData struct {
+	id      string
+	files   fileSystem
+	config  *eng.ContainerConfig
+	labels  map[string]string
+	created time.Time
+	layers  []string // diff IDs, nil for a single layer with all files
+}
+
+func New(config *eng.EngineConfig) *Engine {
+	return &Engine{
+		Scripts:    map[string]Script{},
+		Exit:       config.Exit,
+		Session:    "some-session",
+		lastPort:   32767,
+		containers: map[string]*Container{},
+		images:     map[string]*imageData{},
+		layers:     map[string][]byte{},
+		refs:       map[string]string{},
+		pushed:     map[string]eng.RegistryCreds{},
+	}
+}
+
+func (e *Engine) Close() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.closed = true
+	return nil
+}
+
+func (e *Engine) Closed() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.closed
+}
+
+func (e *Engine) AddImage(ref string, files map[string]string) (imageID string) {
+	fs := newFileSystem()
+	for path, contents := range files {
+		fs.writeFile(path, []byte(contents), 0755)
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.addImage(ref, fs, nil, nil)
+}
+
+func (e *Engine) HasImage(ref string) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	_, ok := e.findImage(ref)
+	return ok
+}
+
+func (e *Engine) ImageFile(ref, path string) ([]byte, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	img, ok := e.findImage(ref)
+	if !ok {
+		return nil, fmt.Errorf("No such image: %s", ref)
+	}
+	return img.files.readFile(path)
+}
+
+func (e *Engine) Pushed(ref string) (creds eng.RegistryCreds, ok bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	creds, ok = e.pushed[normalizeRef(ref)]
+	return creds, ok
+}
+
+func (e *Engine) Containers() []*Container {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	var containers []*Container
+	for _, c := range e.containers {
+		containers = append(containers, c)
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].id < containers[j].id
+	})
+	return containers
+}
+
+func (e *Engine) script(name string) Script {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if script, ok := e.Scripts[name]; ok {
+		return script
+	}
+	if e.DefaultScript != nil {
+		return e.DefaultScript
+	}
+	return func(*Process) int64 { return 0 }
+}
+
+func (e *Engine) newID(kind string) string {
+	e.lastID++
+	return fmt.Sprintf("%s%064x", kind, e.lastID)
+}
+
+func (e *Engine) ImageLabels(ref string) map[string]string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	img, ok := e.findImage(ref)
+	if !ok {
+		return nil
+	}
+	return img.labels
+}
+
+func (e *Engine) labels(role, appName string) map[string]string {
+	labels := map[string]string{
+		eng.LabelSession: e.Session,
+		eng.LabelCreated: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if role != "" {
+		labels[eng.LabelRole] = role
+	}
+	if appName != "" {
+		labels[eng.LabelApp] = appName
+	}
+	return labels
+}
+
+func (e *Engine) addImage(ref string, fs fileSystem, config *eng.ContainerConfig, labels map[string]string) (imageID string) {
+	id := e.newID("sha256:")
+	e.images[id] = &imageData{id, fs, config, labels, time.Now(), nil}
+	if ref != "" {
+		e.refs[normalizeRef(ref)] = id
+	}
+	return id
+}
+
+func (e *Engine) findImage(ref string) (*imageData, bool) {
+	if img, ok := e.images[ref]; ok {
+		return img, true
+	}
+	if id, ok := e.refs[normalizeRef(ref)]; ok {
+		img, ok := e.images[id]
+		return img, ok
+	}
+	return nil, false
+}
+
+func (e *Engine) removeImage(ref string) error {
+	img, ok := e.findImage(ref)
+	if !ok {
+		return fmt.Errorf("No such image: %s", ref)
+	}
+	delete(e.images, img.id)
+	for r, id := range e.refs {
+		if id == img.id {
+			delete(e.refs, r)
+		}
+	}
+	return nil
+}
+
+func normalizeRef(ref string) string {
+	if strings.Contains(ref, "@") {
+		return ref
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref
+	}
+	return ref + ":latest"
+}
@@ -0,0 +1,93 @@
+package fake
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	eng "github.com/buildpack/forge/engine"
+)
+
+type subscriber struct {
+	mutex  sync.Mutex
+	queue  []eng.Event
+	notify chan struct{}
+}
+
+func (e *Engine) Events() <-chan eng.Event {
+	return e.EventsContext(context.Background())
+}
+
+func (e *Engine) EventsContext(ctx context.Context) <-chan eng.Event {
+	sub := &subscriber{notify: make(chan struct{}, 1)}
+	e.subsMutex.Lock()
+	if e.subs == nil {
+		e.subs = map[*subscriber]struct{}{}
+	}
+	e.subs[sub] = struct{}{}
+	e.subsMutex.Unlock()
+
+	out := make(chan eng.Event)
+	go func() {
+		defer close(out)
+		defer func() {
+			e.subsMutex.Lock()
+			defer e.subsMutex.Unlock()
+			delete(e.subs, sub)
+		}()
+		for {
+			sub.mutex.Lock()
+			queue := sub.queue
+			sub.queue = nil
+			sub.mutex.Unlock()
+
+			for _, event := range queue {
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				case <-e.Exit:
+					return
+				}
+			}
+			select {
+			case <-sub.notify:
+			case <-ctx.Done():
+				return
+			case <-e.Exit:
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (e *Engine) publish(event eng.Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	e.subsMutex.Lock()
+	defer e.subsMutex.Unlock()
+	for sub := range e.subs {
+		sub.mutex.Lock()
+		sub.queue = append(sub.queue, event)
+		sub.mutex.Unlock()
+		select {
+		case sub.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (c *Container) event(action eng.EventAction) eng.Event {
+	return eng.Event{
+		Action:      action,
+		ContainerID: c.id,
+		Name:        c.name,
+		Image:       c.config.Image,
+	}
+}
+
+func (c *Container) OOM() {
+	c.engine.publish(c.event(eng.EventOOM))
+}
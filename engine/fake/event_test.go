@@ -0,0 +1,71 @@
+package fake_test
+
+import (
+	"context"
+	"io/ioutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	eng "github.com/buildpack/forge/engine"
+	. "github.com/buildpack/forge/engine/fake"
+)
+
+var _ = Describe("Engine", func() {
+	var (
+		engine *Engine
+		exit   chan struct{}
+	)
+
+	BeforeEach(func() {
+		exit = make(chan struct{})
+		engine = New(&eng.EngineConfig{Exit: exit})
+		engine.AddImage("some-image", nil)
+	})
+
+	Describe("#EventsContext", func() {
+		It("should stream lifecycle events for containers until the context is canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			events := engine.EventsContext(ctx)
+
+			contr, err := engine.NewContainer(&eng.ContainerConfig{Name: "some-name", Image: "some-image"})
+			Expect(err).NotTo(HaveOccurred())
+			engine.Scripts["some-name"] = func(proc *Process) int64 {
+				proc.Container.OOM()
+				return 137
+			}
+			Expect(contr.Start("", ioutil.Discard, nil)).To(Equal(int64(137)))
+			contr.(*Container).SetHealth("healthy")
+			Expect(contr.Close()).To(Succeed())
+
+			var actions []eng.EventAction
+			for range []eng.EventAction{eng.EventCreate, eng.EventStart, eng.EventOOM, eng.EventDie, eng.EventHealthStatus, eng.EventDestroy} {
+				var event eng.Event
+				Eventually(events).Should(Receive(&event))
+				Expect(event.ContainerID).To(Equal(contr.ID()))
+				Expect(event.Name).To(HavePrefix("some-name-"))
+				Expect(event.Image).To(Equal("some-image"))
+				Expect(event.Time).NotTo(BeZero())
+				if event.Action == eng.EventDie {
+					Expect(event.ExitCode).To(Equal(int64(137)))
+				}
+				if event.Action == eng.EventHealthStatus {
+					Expect(event.Health).To(Equal("healthy"))
+				}
+				actions = append(actions, event.Action)
+			}
+			Expect(actions).To(ConsistOf(eng.EventCreate, eng.EventStart, eng.EventOOM, eng.EventDie, eng.EventHealthStatus, eng.EventDestroy))
+			Expect(actions[:3]).To(Equal([]eng.EventAction{eng.EventCreate, eng.EventStart, eng.EventOOM}))
+
+			cancel()
+			Eventually(events).Should(BeClosed())
+		})
+
+		It("should stop streaming when the engine is signaled to exit", func() {
+			events := engine.Events()
+			close(exit)
+			Eventually(events).Should(BeClosed())
+		})
+	})
+})
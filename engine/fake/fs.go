@@ -0,0 +1,255 @@
+package fake
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	gopath "path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type file struct {
+	mode os.FileMode
+	data []byte
+}
+
+type fileSystem map[string]*file
+
+func newFileSystem() fileSystem {
+	return fileSystem{"/": {mode: os.ModeDir | 0755}}
+}
+
+func (fs fileSystem) clone() fileSystem {
+	out := fileSystem{}
+	for path, f := range fs {
+		out[path] = &file{f.mode, append([]byte(nil), f.data...)}
+	}
+	return out
+}
+
+func (fs fileSystem) isDir(path string) bool {
+	f, ok := fs[cleanPath(path)]
+	return ok && f.mode.IsDir()
+}
+
+func (fs fileSystem) mkdirAll(path string, mode os.FileMode) error {
+	path = cleanPath(path)
+	if f, ok := fs[path]; ok {
+		if !f.mode.IsDir() {
+			return fmt.Errorf("%s: not a directory", path)
+		}
+		return nil
+	}
+	if err := fs.mkdirAll(gopath.Dir(path), 0755); err != nil {
+		return err
+	}
+	fs[path] = &file{mode: os.ModeDir | mode.Perm()}
+	return nil
+}
+
+func (fs fileSystem) writeFile(path string, data []byte, mode os.FileMode) error {
+	path = cleanPath(path)
+	if fs.isDir(path) {
+		return fmt.Errorf("%s: cannot write to directory", path)
+	}
+	if err := fs.mkdirAll(gopath.Dir(path), 0755); err != nil {
+		return err
+	}
+	fs[path] = &file{mode.Perm(), data}
+	return nil
+}
+
+func (fs fileSystem) readFile(path string) ([]byte, error) {
+	f, ok := fs[cleanPath(path)]
+	if !ok {
+		return nil, fmt.Errorf("%s: no such file or directory", path)
+	}
+	if f.mode.IsDir() {
+		return nil, fmt.Errorf("%s: is a directory", path)
+	}
+	return f.data, nil
+}
+
+func (fs fileSystem) readTar(archive io.Reader, dest string) error {
+	dest = cleanPath(dest)
+	tarball := tar.NewReader(archive)
+	for {
+		header, err := tarball.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		path := gopath.Join(dest, header.Name)
+		if !strings.HasPrefix(path+"/", dest+"/") && dest != "/" {
+			return fmt.Errorf("%s: outside of %s", header.Name, dest)
+		}
+		mode := os.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := fs.mkdirAll(path, mode); err != nil {
+				return err
+			}
+			fs[cleanPath(path)].mode = os.ModeDir | mode
+		case tar.TypeReg, tar.TypeRegA:
+			data := &bytes.Buffer{}
+			if _, err := io.Copy(data, tarball); err != nil {
+				return err
+			}
+			if err := fs.writeFile(path, data.Bytes(), mode); err != nil {
+				return err
+			}
+		default:
+			return errors.New("unsupported tar entry: " + header.Name)
+		}
+	}
+}
+
+func (fs fileSystem) writeTar(dir string) ([]byte, error) {
+	dir = cleanPath(dir)
+	var paths []string
+	for path := range fs {
+		if path != dir && strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/") {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	tarBuffer := &bytes.Buffer{}
+	tarball := tar.NewWriter(tarBuffer)
+	if err := writeTarEntry(tarball, "./", fs[dir]); err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		name := "./" + strings.TrimPrefix(strings.TrimPrefix(path, dir), "/")
+		if err := writeTarEntry(tarball, name, fs[path]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tarball.Close(); err != nil {
+		return nil, err
+	}
+	return tarBuffer.Bytes(), nil
+}
+
+func writeTarEntry(tarball *tar.Writer, name string, f *file) error {
+	if f.mode.IsDir() {
+		return tarball.WriteHeader(&tar.Header{
+			Name:     strings.TrimSuffix(name, "/") + "/",
+			Mode:     040000 | int64(f.mode.Perm()),
+			Typeflag: tar.TypeDir,
+		})
+	}
+	if err := tarball.WriteHeader(&tar.Header{
+		Name:     name,
+		Size:     int64(len(f.data)),
+		Mode:     0100000 | int64(f.mode.Perm()),
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	_, err := tarball.Write(f.data)
+	return err
+}
+
+func cleanPath(path string) string {
+	return gopath.Clean("/" + path)
+}
+
+func (fs fileSystem) readDir(dir string) error {
+	excludes, err := readDockerignore(dir)
+	if err != nil {
+		return err
+	}
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." && rel != ".dockerignore" && excluded(rel, excludes) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return fs.mkdirAll(rel, info.Mode())
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return fs.writeFile(rel, data, info.Mode())
+	})
+}
+
+func (fs fileSystem) copyFrom(src fileSystem, srcPath, destPath string) error {
+	srcPath = cleanPath(srcPath)
+	f, ok := src[srcPath]
+	if !ok {
+		return fmt.Errorf("%s: no such file or directory", srcPath)
+	}
+	if !f.mode.IsDir() {
+		if strings.HasSuffix(destPath, "/") || fs.isDir(destPath) {
+			destPath = gopath.Join(destPath, gopath.Base(srcPath))
+		}
+		return fs.writeFile(destPath, append([]byte(nil), f.data...), f.mode)
+	}
+	if err := fs.mkdirAll(destPath, f.mode); err != nil {
+		return err
+	}
+	for path, f := range src {
+		if path == srcPath || !strings.HasPrefix(path, strings.TrimSuffix(srcPath, "/")+"/") {
+			continue
+		}
+		dest := gopath.Join(destPath, strings.TrimPrefix(path, srcPath))
+		if f.mode.IsDir() {
+			if err := fs.mkdirAll(dest, f.mode); err != nil {
+				return err
+			}
+		} else if err := fs.writeFile(dest, append([]byte(nil), f.data...), f.mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readDockerignore(dir string) ([]string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ".dockerignore"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns, nil
+}
+
+func excluded(path string, patterns []string) bool {
+	var match bool
+	for _, pattern := range patterns {
+		negate := strings.HasPrefix(pattern, "!")
+		pattern = gopath.Clean(strings.TrimPrefix(pattern, "!"))
+		if ok, _ := gopath.Match(pattern, path); ok {
+			match = !negate
+		}
+	}
+	return match
+}
@@ -0,0 +1,62 @@
+package fake
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	eng "github.com/buildpack/forge/engine"
+)
+
+// GC treats every session other than Engine.Session as dead.
+// Untagged images are only removed when older than MaxAge.
+func (e *Engine) GC(config *eng.GCConfig) (orphans []eng.Resource, err error) {
+	return e.GCContext(context.Background(), config)
+}
+
+func (e *Engine) GCContext(ctx context.Context, config *eng.GCConfig) (orphans []eng.Resource, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var removed []*Container
+	for _, contr := range e.Containers() {
+		if !e.orphaned(contr.labels) {
+			continue
+		}
+		orphans = append(orphans, eng.NewResource(eng.ContainerResource, contr.id, contr.labels))
+		removed = append(removed, contr)
+	}
+	if !config.DryRun {
+		for _, contr := range removed {
+			if err := contr.Close(); err != nil {
+				return orphans, err
+			}
+		}
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	tagged := map[string]bool{}
+	for _, id := range e.refs {
+		tagged[id] = true
+	}
+	var images []eng.Resource
+	for id, img := range e.images {
+		if tagged[id] || config.MaxAge == 0 || !e.orphaned(img.labels) || time.Since(img.created) <= config.MaxAge {
+			continue
+		}
+		images = append(images, eng.NewResource(eng.ImageResource, id, img.labels))
+		if !config.DryRun {
+			delete(e.images, id)
+		}
+	}
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].ID < images[j].ID
+	})
+	return append(orphans, images...), nil
+}
+
+func (e *Engine) orphaned(labels map[string]string) bool {
+	session, ok := labels[eng.LabelSession]
+	return ok && session != e.Session
+}
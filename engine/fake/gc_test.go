@@ -0,0 +1,89 @@
+package fake_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	eng "github.com/buildpack/forge/engine"
+	. "github.com/buildpack/forge/engine/fake"
+)
+
+var _ = Describe("Engine", func() {
+	var engine *Engine
+
+	BeforeEach(func() {
+		engine = New(&eng.EngineConfig{})
+		engine.AddImage("some-image", nil)
+	})
+
+	Describe("#GC", func() {
+		It("should remove containers and old untagged images from other sessions", func() {
+			contr, err := engine.NewContainer(&eng.ContainerConfig{
+				Name:    "some-name",
+				AppName: "some-app",
+				Role:    eng.RoleStaging,
+				Image:   "some-image",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(contr.(*Container).Labels()).To(HaveKeyWithValue(eng.LabelSession, "some-session"))
+			Expect(contr.(*Container).Labels()).To(HaveKeyWithValue(eng.LabelRole, eng.RoleStaging))
+			Expect(contr.(*Container).Labels()).To(HaveKeyWithValue(eng.LabelApp, "some-app"))
+
+			tagged, err := contr.Commit("some-ref")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(engine.ImageLabels(tagged)).To(HaveKeyWithValue(eng.LabelRole, eng.RoleStaging))
+			untagged, err := contr.Commit("")
+			Expect(err).NotTo(HaveOccurred())
+
+			orphans, err := engine.GC(&eng.GCConfig{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(orphans).To(BeEmpty())
+
+			engine.Session = "some-other-session"
+			current, err := engine.NewContainer(&eng.ContainerConfig{Name: "some-name", Image: "some-image"})
+			Expect(err).NotTo(HaveOccurred())
+
+			orphans, err = engine.GC(&eng.GCConfig{MaxAge: time.Nanosecond, DryRun: true})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(orphans).To(HaveLen(2))
+			Expect(engine.Containers()).To(HaveLen(2))
+
+			orphans, err = engine.GC(&eng.GCConfig{MaxAge: time.Nanosecond})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(orphans).To(HaveLen(2))
+			Expect(orphans[0].Type).To(Equal(eng.ContainerResource))
+			Expect(orphans[0].ID).To(Equal(contr.ID()))
+			Expect(orphans[0].Session).To(Equal("some-session"))
+			Expect(orphans[0].Role).To(Equal(eng.RoleStaging))
+			Expect(orphans[0].AppName).To(Equal("some-app"))
+			Expect(orphans[0].Created).To(BeTemporally("~", time.Now(), time.Minute))
+			Expect(orphans[1].Type).To(Equal(eng.ImageResource))
+			Expect(orphans[1].ID).To(Equal(untagged))
+
+			Expect(engine.Containers()).To(Equal([]*Container{current.(*Container)}))
+			Expect(engine.HasImage(tagged)).To(BeTrue())
+			Expect(engine.HasImage(untagged)).To(BeFalse())
+		})
+
+		It("should not remove untagged images without a max age or from the current session", func() {
+			contr, err := engine.NewContainer(&eng.ContainerConfig{Name: "some-name", Image: "some-image"})
+			Expect(err).NotTo(HaveOccurred())
+			untagged, err := contr.Commit("")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(contr.Close()).To(Succeed())
+
+			orphans, err := engine.GC(&eng.GCConfig{MaxAge: time.Nanosecond})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(orphans).To(BeEmpty())
+			Expect(engine.HasImage(untagged)).To(BeTrue())
+
+			engine.Session = "some-other-session"
+			orphans, err = engine.GC(&eng.GCConfig{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(orphans).To(BeEmpty())
+			Expect(engine.HasImage(untagged)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,565 @@
+package fake
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	gopath "path"
+	"sort"
+	"strings"
+	"time"
+
+	eng "github.com/buildpack/forge/engine"
+)
+
+type image struct {
+	engine *Engine
+}
+
+func (e *Engine) NewImage() eng.Image {
+	return &image{e}
+}
+
+func (i *image) Build(tag string, dockerfile eng.Stream) <-chan eng.Progress {
+	return i.BuildContext(context.Background(), tag, dockerfile)
+}
+
+func (i *image) BuildContext(ctx context.Context, tag string, dockerfile eng.Stream) <-chan eng.Progress {
+	defer dockerfile.Close()
+	buildContext := newFileSystem()
+	dockerfileBuf := &bytes.Buffer{}
+	if _, err := io.CopyN(dockerfileBuf, dockerfile, dockerfile.Remaining()); err != nil {
+		progress := make(chan eng.Progress, 1)
+		defer close(progress)
+		progress <- progressError(err)
+		return progress
+	}
+	buildContext.writeFile("Dockerfile", dockerfileBuf.Bytes(), 0644)
+	return i.build(ctx, buildContext, &eng.BuildConfig{Tags: []string{tag}})
+}
+
+func (i *image) BuildFrom(config *eng.BuildConfig) <-chan eng.Progress {
+	return i.BuildFromContext(context.Background(), config)
+}
+
+func (i *image) BuildFromContext(ctx context.Context, config *eng.BuildConfig) <-chan eng.Progress {
+	buildContext := newFileSystem()
+	var err error
+	if config.Context != nil {
+		err = buildContext.readTar(config.Context, "/")
+	} else {
+		err = buildContext.readDir(config.ContextDir)
+	}
+	if err != nil {
+		progress := make(chan eng.Progress, 1)
+		defer close(progress)
+		progress <- progressError(err)
+		return progress
+	}
+	return i.build(ctx, buildContext, config)
+}
+
+type buildStage struct {
+	from, name string
+	copies     [][2]string
+}
+
+func (i *image) build(ctx context.Context, buildContext fileSystem, config *eng.BuildConfig) <-chan eng.Progress {
+	progress := make(chan eng.Progress, 2)
+	defer close(progress)
+
+	if err := ctx.Err(); err != nil {
+		progress <- progressError(err)
+		return progress
+	}
+
+	dockerfile := config.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+	data, err := buildContext.readFile(dockerfile)
+	if err != nil {
+		progress <- progressError(err)
+		return progress
+	}
+	var stages []*buildStage
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "FROM":
+			stage := &buildStage{from: fields[1]}
+			if len(fields) == 4 && strings.ToUpper(fields[2]) == "AS" {
+				stage.name = fields[3]
+			}
+			stages = append(stages, stage)
+		case "COPY", "ADD":
+			if len(stages) > 0 && len(fields) >= 3 {
+				stage := stages[len(stages)-1]
+				stage.copies = append(stage.copies, [2]string{fields[1], fields[len(fields)-1]})
+			}
+		}
+	}
+	if len(stages) == 0 {
+		progress <- progressErrorString("Dockerfile parse error: missing FROM")
+		return progress
+	}
+	stage := stages[len(stages)-1]
+	if config.Target != "" {
+		stage = nil
+		for _, s := range stages {
+			if s.name == config.Target {
+				stage = s
+				break
+			}
+		}
+		if stage == nil {
+			progress <- progressErrorString(fmt.Sprintf("failed to reach build target %s in Dockerfile", config.Target))
+			return progress
+		}
+	}
+
+	i.engine.mutex.Lock()
+	defer i.engine.mutex.Unlock()
+	base, ok := i.engine.findImage(stage.from)
+	if !ok {
+		progress <- progressErrorString(fmt.Sprintf("pull access denied for %s", stage.from))
+		return progress
+	}
+	files := base.files.clone()
+	for _, c := range stage.copies {
+		if err := files.copyFrom(buildContext, c[0], c[1]); err != nil {
+			progress <- progressError(err)
+			return progress
+		}
+	}
+	labels := i.engine.labels(eng.RoleBuild, "")
+	for k, v := range config.Labels {
+		labels[k] = v
+	}
+	var tag string
+	if len(config.Tags) > 0 {
+		tag = config.Tags[0]
+	}
+	id := i.engine.addImage(tag, files, base.config, labels)
+	for _, tag := range config.Tags {
+		i.engine.refs[normalizeRef(tag)] = id
+	}
+	progress <- eng.Progress{Stream: fmt.Sprintf("Successfully built %s\n", id[7:19])}
+	progress <- eng.Progress{Aux: &eng.ProgressAux{ImageID: id}}
+	return progress
+}
+
+func (i *image) Pull(ref string) <-chan eng.Progress {
+	return i.PullContext(context.Background(), ref)
+}
+
+func (i *image) PullContext(ctx context.Context, ref string) <-chan eng.Progress {
+	progress := make(chan eng.Progress, 1)
+	defer close(progress)
+
+	if err := ctx.Err(); err != nil {
+		progress <- progressError(err)
+		return progress
+	}
+
+	i.engine.mutex.Lock()
+	defer i.engine.mutex.Unlock()
+	if _, ok := i.engine.findImage(ref); !ok {
+		i.engine.addImage(ref, newFileSystem(), nil, nil)
+	}
+	progress <- eng.Progress{}
+	return progress
+}
+
+func (i *image) Push(ref string, creds eng.RegistryCreds) <-chan eng.Progress {
+	return i.PushContext(context.Background(), ref, creds)
+}
+
+func (i *image) PushContext(ctx context.Context, ref string, creds eng.RegistryCreds) <-chan eng.Progress {
+	progress := make(chan eng.Progress, 1)
+	defer close(progress)
+
+	if err := ctx.Err(); err != nil {
+		progress <- progressError(err)
+		return progress
+	}
+
+	i.engine.mutex.Lock()
+	defer i.engine.mutex.Unlock()
+	img, ok := i.engine.findImage(ref)
+	if !ok {
+		progress <- progressErrorString(fmt.Sprintf("An image does not exist locally with the tag: %s", ref))
+		return progress
+	}
+	ref = normalizeRef(ref)
+	i.engine.pushed[ref] = creds
+	var tag string
+	if !strings.Contains(ref, "@") {
+		tag = ref[strings.LastIndex(ref, ":")+1:]
+	}
+	var size int64
+	for _, f := range img.files {
+		size += int64(len(f.data))
+	}
+	progress <- eng.Progress{Aux: &eng.ProgressAux{
+		Tag:    tag,
+		Digest: img.id,
+		Size:   size,
+	}}
+	return progress
+}
+
+type archiveManifest struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+type archiveConfig struct {
+	Created      time.Time `json:"created"`
+	OS           string    `json:"os"`
+	Architecture string    `json:"architecture"`
+	Config       struct {
+		Env        []string          `json:",omitempty"`
+		Entrypoint []string          `json:",omitempty"`
+		Cmd        []string          `json:",omitempty"`
+		WorkingDir string            `json:",omitempty"`
+		User       string            `json:",omitempty"`
+		Labels     map[string]string `json:",omitempty"`
+	} `json:"config"`
+	RootFS struct {
+		Type    string   `json:"type"`
+		DiffIDs []string `json:"diff_ids"`
+	} `json:"rootfs"`
+}
+
+func (i *image) Save(progress chan<- eng.Progress, refs ...string) (eng.Stream, error) {
+	return i.SaveContext(context.Background(), progress, refs...)
+}
+
+func (i *image) SaveContext(ctx context.Context, progress chan<- eng.Progress, refs ...string) (eng.Stream, error) {
+	if err := ctx.Err(); err != nil {
+		return eng.Stream{}, err
+	}
+
+	i.engine.mutex.Lock()
+	defer i.engine.mutex.Unlock()
+	var manifest []*archiveManifest
+	saved := map[string]*archiveManifest{}
+	files := fileSystem{}
+	for _, ref := range refs {
+		img, ok := i.engine.findImage(ref)
+		if !ok {
+			return eng.Stream{}, fmt.Errorf("No such image: %s", ref)
+		}
+		m, ok := saved[img.id]
+		if !ok {
+			layers, err := i.engine.imageLayers(img)
+			if err != nil {
+				return eng.Stream{}, err
+			}
+			config := &archiveConfig{
+				Created:      img.created,
+				OS:           "linux",
+				Architecture: "amd64",
+			}
+			config.Config.Labels = img.labels
+			if c := img.config; c != nil {
+				config.Config.Env = c.Env
+				config.Config.Entrypoint = c.Entrypoint
+				config.Config.Cmd = c.Cmd
+				config.Config.WorkingDir = c.WorkingDir
+				config.Config.User = c.User
+			}
+			config.RootFS.Type = "layers"
+			config.RootFS.DiffIDs = layers
+			configJSON, err := json.Marshal(config)
+			if err != nil {
+				return eng.Stream{}, err
+			}
+			m = &archiveManifest{Config: strings.TrimPrefix(img.id, "sha256:") + ".json"}
+			files[m.Config] = &file{0644, configJSON}
+			for _, diffID := range layers {
+				layerPath := strings.TrimPrefix(diffID, "sha256:") + "/layer.tar"
+				m.Layers = append(m.Layers, layerPath)
+				files[layerPath] = &file{0644, i.engine.layers[diffID]}
+			}
+			saved[img.id] = m
+			manifest = append(manifest, m)
+		}
+		if ref != img.id {
+			m.RepoTags = append(m.RepoTags, normalizeRef(ref))
+		}
+	}
+
+	manifestJSON, err := json.Marshal(manifest)
+	if err != nil {
+		return eng.Stream{}, err
+	}
+	files["manifest.json"] = &file{0644, manifestJSON}
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tarBuffer := &bytes.Buffer{}
+	tarball := tar.NewWriter(tarBuffer)
+	for _, name := range names {
+		if err := writeTarEntry(tarball, name, files[name]); err != nil {
+			return eng.Stream{}, err
+		}
+	}
+	if err := tarball.Close(); err != nil {
+		return eng.Stream{}, err
+	}
+	return eng.NewProgressStream(eng.NewStream(ioutil.NopCloser(tarBuffer), int64(tarBuffer.Len())), "save", progress), nil
+}
+
+func (i *image) Load(image eng.Stream) <-chan eng.Progress {
+	return i.LoadContext(context.Background(), image)
+}
+
+func (i *image) LoadContext(ctx context.Context, image eng.Stream) <-chan eng.Progress {
+	defer image.Close()
+	loaded, err := i.load(ctx, image)
+	progress := make(chan eng.Progress, len(loaded)+1)
+	defer close(progress)
+	for _, p := range loaded {
+		progress <- p
+	}
+	if err != nil {
+		progress <- progressError(err)
+	}
+	return progress
+}
+
+// load accepts archives in the docker save format. Layers missing from the
+// archive are taken from existing images with the same diff ID.
+func (i *image) load(ctx context.Context, image io.Reader) ([]eng.Progress, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	entries := map[string][]byte{}
+	tarball := tar.NewReader(image)
+	for {
+		header, err := tarball.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(tarball)
+		if err != nil {
+			return nil, err
+		}
+		entries[gopath.Clean(header.Name)] = data
+	}
+	var manifest []*archiveManifest
+	if err := json.Unmarshal(entries["manifest.json"], &manifest); err != nil {
+		return nil, errors.New("invalid image archive: missing or invalid manifest.json")
+	}
+
+	i.engine.mutex.Lock()
+	defer i.engine.mutex.Unlock()
+	var progress []eng.Progress
+	for _, m := range manifest {
+		configJSON, ok := entries[m.Config]
+		if !ok {
+			return progress, fmt.Errorf("invalid image archive: missing %s", m.Config)
+		}
+		var config archiveConfig
+		if err := json.Unmarshal(configJSON, &config); err != nil {
+			return progress, err
+		}
+		if len(config.RootFS.DiffIDs) != len(m.Layers) {
+			return progress, errors.New("invalid image archive: layers do not match diff IDs")
+		}
+		files := newFileSystem()
+		for j, layerPath := range m.Layers {
+			diffID := config.RootFS.DiffIDs[j]
+			layer, ok := entries[gopath.Clean(layerPath)]
+			if ok {
+				if fmt.Sprintf("sha256:%x", sha256.Sum256(layer)) != diffID {
+					return progress, fmt.Errorf("invalid image archive: layer %s does not match diff ID %s", layerPath, diffID)
+				}
+				i.engine.layers[diffID] = layer
+			} else if layer, ok = i.engine.layers[diffID]; !ok {
+				return progress, fmt.Errorf("invalid image archive: missing layer %s", diffID)
+			}
+			if err := files.readTar(bytes.NewReader(layer), "/"); err != nil {
+				return progress, err
+			}
+		}
+		id := fmt.Sprintf("sha256:%x", sha256.Sum256(configJSON))
+		i.engine.images[id] = &imageData{id, files, &eng.ContainerConfig{
+			Env:        config.Config.Env,
+			Entrypoint: config.Config.Entrypoint,
+			Cmd:        config.Config.Cmd,
+			WorkingDir: config.Config.WorkingDir,
+			User:       config.Config.User,
+		}, config.Config.Labels, config.Created, config.RootFS.DiffIDs}
+		for _, tag := range m.RepoTags {
+			i.engine.refs[normalizeRef(tag)] = id
+			progress = append(progress, eng.Progress{Stream: fmt.Sprintf("Loaded image: %s\n", tag)})
+		}
+		if len(m.RepoTags) == 0 {
+			progress = append(progress, eng.Progress{Stream: fmt.Sprintf("Loaded image ID: %s\n", id)})
+		}
+		progress = append(progress, eng.Progress{Aux: &eng.ProgressAux{ImageID: id}})
+	}
+	return progress, nil
+}
+
+func (i *image) Inspect(ref string) (*eng.ImageInfo, error) {
+	return i.InspectContext(context.Background(), ref)
+}
+
+func (i *image) InspectContext(ctx context.Context, ref string) (*eng.ImageInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	i.engine.mutex.Lock()
+	defer i.engine.mutex.Unlock()
+	img, ok := i.engine.findImage(ref)
+	if !ok {
+		return nil, fmt.Errorf("Error: No such image: %s", ref)
+	}
+	layers, err := i.engine.imageLayers(img)
+	if err != nil {
+		return nil, err
+	}
+	info := i.engine.imageInfo(img)
+	info.Layers = layers
+	for range layers {
+		info.History = append(info.History, eng.ImageHistory{Created: img.created})
+	}
+	info.OS = "linux"
+	info.Architecture = "amd64"
+	if config := img.config; config != nil {
+		info.Env = config.Env
+		info.Entrypoint = config.Entrypoint
+		info.Cmd = config.Cmd
+		info.WorkingDir = config.WorkingDir
+		info.User = config.User
+	}
+	return &info, nil
+}
+
+func (i *image) List(labels map[string]string) ([]eng.ImageInfo, error) {
+	return i.ListContext(context.Background(), labels)
+}
+
+func (i *image) ListContext(ctx context.Context, labels map[string]string) ([]eng.ImageInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	i.engine.mutex.Lock()
+	defer i.engine.mutex.Unlock()
+	var images []eng.ImageInfo
+	for _, img := range i.engine.images {
+		if matchLabels(img.labels, labels) {
+			images = append(images, i.engine.imageInfo(img))
+		}
+	}
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].ID < images[j].ID
+	})
+	return images, nil
+}
+
+func (i *image) Tag(ref, tag string) error {
+	return i.TagContext(context.Background(), ref, tag)
+}
+
+func (i *image) TagContext(ctx context.Context, ref, tag string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	i.engine.mutex.Lock()
+	defer i.engine.mutex.Unlock()
+	img, ok := i.engine.findImage(ref)
+	if !ok {
+		return fmt.Errorf("Error: No such image: %s", ref)
+	}
+	i.engine.refs[normalizeRef(tag)] = img.id
+	return nil
+}
+
+func (i *image) Exists(ref string) (bool, error) {
+	return i.ExistsContext(context.Background(), ref)
+}
+
+func (i *image) ExistsContext(ctx context.Context, ref string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	i.engine.mutex.Lock()
+	defer i.engine.mutex.Unlock()
+	_, ok := i.engine.findImage(ref)
+	return ok, nil
+}
+
+func (e *Engine) imageInfo(img *imageData) eng.ImageInfo {
+	info := eng.ImageInfo{
+		ID:      img.id,
+		Labels:  img.labels,
+		Created: img.created,
+	}
+	for ref, id := range e.refs {
+		if id != img.id {
+			continue
+		}
+		info.RepoTags = append(info.RepoTags, ref)
+		if _, ok := e.pushed[ref]; ok && !strings.Contains(ref, "@") {
+			info.RepoDigests = append(info.RepoDigests, ref[:strings.LastIndex(ref, ":")]+"@"+img.id)
+		}
+	}
+	sort.Strings(info.RepoTags)
+	sort.Strings(info.RepoDigests)
+	for _, f := range img.files {
+		info.Size += int64(len(f.data))
+	}
+	return info
+}
+
+// imageLayers returns the diff IDs of an image. Images that were not loaded
+// have a single layer with all of their files.
+func (e *Engine) imageLayers(img *imageData) ([]string, error) {
+	if img.layers != nil {
+		return img.layers, nil
+	}
+	layer, err := img.files.writeTar("/")
+	if err != nil {
+		return nil, err
+	}
+	diffID := fmt.Sprintf("sha256:%x", sha256.Sum256(layer))
+	e.layers[diffID] = layer
+	return []string{diffID}, nil
+}
+
+func matchLabels(labels, filter map[string]string) bool {
+	for k, v := range filter {
+		if value, ok := labels[k]; !ok || v != "" && value != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (i *image) Delete(id string) error {
+	i.engine.mutex.Lock()
+	defer i.engine.mutex.Unlock()
+	return i.engine.removeImage(id)
+}
@@ -0,0 +1,291 @@
+package fake_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	eng "github.com/buildpack/forge/engine"
+	. "github.com/buildpack/forge/engine/fake"
+)
+
+var _ = Describe("Image", func() {
+	var engine *Engine
+
+	BeforeEach(func() {
+		engine = New(&eng.EngineConfig{})
+		engine.AddImage("some-base", map[string]string{"/some-file": "some-data"})
+	})
+
+	Describe("#Build", func() {
+		It("should create an image from the base image", func() {
+			dockerfile := bytes.NewBufferString("FROM some-base\nRUN true\n")
+			dockerfileStream := eng.NewStream(ioutil.NopCloser(dockerfile), int64(dockerfile.Len()))
+			var imageID, output string
+			for p := range engine.NewImage().Build("some-tag", dockerfileStream) {
+				Expect(p.Status()).To(Equal("N/A"))
+				if p.Aux != nil {
+					imageID = p.Aux.ImageID
+				}
+				output += p.Stream
+			}
+			Expect(engine.ImageFile("some-tag", "/some-file")).To(Equal([]byte("some-data")))
+			Expect(engine.HasImage(imageID)).To(BeTrue())
+			Expect(output).To(Equal("Successfully built " + imageID[7:19] + "\n"))
+		})
+
+		It("should send an error when the base image is missing", func() {
+			dockerfile := bytes.NewBufferString("FROM some-bad-base\n")
+			dockerfileStream := eng.NewStream(ioutil.NopCloser(dockerfile), int64(dockerfile.Len()))
+			var err error
+			for p := range engine.NewImage().Build("some-tag", dockerfileStream) {
+				_, err = p.Status()
+			}
+			Expect(err).To(MatchError(ContainSubstring("some-bad-base")))
+			Expect(engine.HasImage("some-tag")).To(BeFalse())
+		})
+	})
+
+	Describe("#BuildFrom", func() {
+		var contextDir string
+
+		BeforeEach(func() {
+			var err error
+			contextDir, err = ioutil.TempDir("", "forge.fake.test")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ioutil.WriteFile(filepath.Join(contextDir, "some-file"), []byte("some-context-data"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(contextDir, "some-ignored-file"), []byte("some-ignored-data"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(contextDir, ".dockerignore"), []byte("some-ignored-*\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(contextDir, "some.Dockerfile"), []byte(
+				"FROM some-base AS some-stage\nCOPY . /some-dir\nFROM some-bad-base\n",
+			), 0644)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(contextDir)).To(Succeed())
+		})
+
+		It("should build the target stage from a context directory and tag the image", func() {
+			var imageID string
+			for p := range engine.NewImage().BuildFrom(&eng.BuildConfig{
+				ContextDir: contextDir,
+				Dockerfile: "some.Dockerfile",
+				Tags:       []string{"some-tag", "some-other-tag"},
+				Labels:     map[string]string{"some-label": "some-value"},
+				Target:     "some-stage",
+			}) {
+				_, err := p.Status()
+				Expect(err).NotTo(HaveOccurred())
+				if p.Aux != nil {
+					imageID = p.Aux.ImageID
+				}
+			}
+			Expect(engine.ImageFile("some-tag", "/some-dir/some-file")).To(Equal([]byte("some-context-data")))
+			Expect(engine.ImageFile("some-other-tag", "/some-file")).To(Equal([]byte("some-data")))
+			_, err := engine.ImageFile(imageID, "/some-dir/some-ignored-file")
+			Expect(err).To(HaveOccurred())
+			Expect(engine.ImageLabels("some-tag")).To(HaveKeyWithValue("some-label", "some-value"))
+		})
+
+		It("should send an error when the target stage is missing", func() {
+			var err error
+			for p := range engine.NewImage().BuildFrom(&eng.BuildConfig{
+				ContextDir: contextDir,
+				Dockerfile: "some.Dockerfile",
+				Target:     "some-bad-stage",
+			}) {
+				_, err = p.Status()
+			}
+			Expect(err).To(MatchError(ContainSubstring("some-bad-stage")))
+		})
+	})
+
+	Describe("#Inspect / #List / #Tag / #Exists", func() {
+		It("should inspect, list and tag images", func() {
+			contr, err := engine.NewContainer(&eng.ContainerConfig{
+				Name:       "some-name",
+				Image:      "some-base",
+				Env:        []string{"SOME_KEY=some-value"},
+				Entrypoint: []string{"some-entrypoint"},
+				Role:       eng.RoleExport,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			id, err := contr.Commit("some-ref")
+			Expect(err).NotTo(HaveOccurred())
+
+			image := engine.NewImage()
+			Expect(image.Exists("some-ref")).To(BeTrue())
+			Expect(image.Exists("some-other-ref")).To(BeFalse())
+			Expect(image.Tag("some-ref", "some-other-ref")).To(Succeed())
+			Expect(image.Exists("some-other-ref")).To(BeTrue())
+
+			info, err := image.Inspect("some-other-ref")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.ID).To(Equal(id))
+			Expect(info.RepoTags).To(Equal([]string{"some-other-ref:latest", "some-ref:latest"}))
+			Expect(info.Labels).To(HaveKeyWithValue(eng.LabelRole, eng.RoleExport))
+			Expect(info.Env).To(Equal([]string{"SOME_KEY=some-value"}))
+			Expect(info.Entrypoint).To(Equal([]string{"some-entrypoint"}))
+			Expect(info.Created).To(BeTemporally("~", time.Now(), time.Minute))
+			Expect(info.Size).To(Equal(int64(len("some-data"))))
+			Expect(info.Layers).To(HaveLen(1))
+			Expect(info.History).To(HaveLen(1))
+
+			images, err := image.List(map[string]string{eng.LabelRole: eng.RoleExport})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(images).To(HaveLen(1))
+			Expect(images[0].ID).To(Equal(id))
+			images, err = image.List(map[string]string{eng.LabelSession: ""})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(images).To(HaveLen(1))
+			images, err = image.List(nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(images).To(HaveLen(2))
+
+			_, err = image.Inspect("some-missing-ref")
+			Expect(err).To(MatchError("Error: No such image: some-missing-ref"))
+		})
+	})
+
+	Describe("#Save / #Load", func() {
+		It("should save images to a tar archive and load them back", func() {
+			contr, err := engine.NewContainer(&eng.ContainerConfig{
+				Name:       "some-name",
+				Image:      "some-base",
+				Entrypoint: []string{"some-entrypoint"},
+				Role:       eng.RoleExport,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = contr.Commit("some-ref")
+			Expect(err).NotTo(HaveOccurred())
+			baseInfo, err := engine.NewImage().Inspect("some-base")
+			Expect(err).NotTo(HaveOccurred())
+
+			progress := make(chan eng.Progress, 10)
+			archive, err := engine.NewImage().Save(progress, "some-ref", baseInfo.ID)
+			Expect(err).NotTo(HaveOccurred())
+
+			otherEngine := New(&eng.EngineConfig{})
+			var loaded []string
+			var output string
+			for p := range otherEngine.NewImage().Load(archive) {
+				_, err := p.Status()
+				Expect(err).NotTo(HaveOccurred())
+				if p.Aux != nil {
+					loaded = append(loaded, p.Aux.ImageID)
+				}
+				output += p.Stream
+			}
+			Expect(loaded).To(HaveLen(2))
+			Expect(output).To(Equal("Loaded image: some-ref:latest\nLoaded image ID: " + loaded[1] + "\n"))
+			close(progress)
+			var last eng.Progress
+			for p := range progress {
+				last = p
+			}
+			Expect(last.ID).To(Equal("save"))
+			Expect(last.Message).To(Equal("Transferred"))
+			Expect(last.Current).To(Equal(archive.Size))
+			Expect(otherEngine.ImageFile("some-ref", "/some-file")).To(Equal([]byte("some-data")))
+			Expect(otherEngine.ImageLabels("some-ref")).To(HaveKeyWithValue(eng.LabelRole, eng.RoleExport))
+			info, err := otherEngine.NewImage().Inspect("some-ref")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.ID).To(Equal(loaded[0]))
+			Expect(info.Entrypoint).To(Equal([]string{"some-entrypoint"}))
+			Expect(otherEngine.HasImage("some-base")).To(BeFalse())
+			Expect(otherEngine.ImageFile(loaded[1], "/some-file")).To(Equal([]byte("some-data")))
+		})
+
+		It("should take layers missing from the archive from existing images", func() {
+			baseInfo, err := engine.NewImage().Inspect("some-base")
+			Expect(err).NotTo(HaveOccurred())
+			appLayer := testTar(map[string][]byte{"some-app-file": []byte("some-app-data")})
+			archive := testTar(map[string][]byte{
+				"manifest.json": []byte(`[{"Config": "some-config.json", "RepoTags": ["some-ref"], "Layers": ["some-base/layer.tar", "some-app/layer.tar"]}]`),
+				"some-config.json": []byte(`{
+					"config": {"Env": ["SOME_KEY=some-value"]},
+					"rootfs": {"type": "layers", "diff_ids": ["` + baseInfo.Layers[0] + `", "` + fmt.Sprintf("sha256:%x", sha256.Sum256(appLayer)) + `"]}
+				}`),
+				"some-app/layer.tar": appLayer,
+			})
+			var loadErr error
+			for p := range engine.NewImage().Load(eng.NewStream(ioutil.NopCloser(bytes.NewReader(archive)), int64(len(archive)))) {
+				if _, err := p.Status(); err != nil {
+					loadErr = err
+				}
+			}
+			Expect(loadErr).NotTo(HaveOccurred())
+			Expect(engine.ImageFile("some-ref", "/some-file")).To(Equal([]byte("some-data")))
+			Expect(engine.ImageFile("some-ref", "/some-app-file")).To(Equal([]byte("some-app-data")))
+			info, err := engine.NewImage().Inspect("some-ref")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Env).To(Equal([]string{"SOME_KEY=some-value"}))
+			Expect(info.Layers).To(Equal([]string{baseInfo.Layers[0], fmt.Sprintf("sha256:%x", sha256.Sum256(appLayer))}))
+		})
+
+		It("should return an error when the image does not exist", func() {
+			_, err := engine.NewImage().Save(nil, "some-missing-ref")
+			Expect(err).To(MatchError("No such image: some-missing-ref"))
+		})
+
+		It("should send an error when the archive is invalid", func() {
+			archive := bytes.NewBufferString("some-bad-archive")
+			var err error
+			for p := range engine.NewImage().Load(eng.NewStream(ioutil.NopCloser(archive), int64(archive.Len()))) {
+				_, err = p.Status()
+			}
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("#Pull / #Push / #Delete", func() {
+		It("should manage images by reference", func() {
+			for p := range engine.NewImage().Pull("some-ref") {
+				Expect(p.Status()).To(Equal("N/A"))
+			}
+			Expect(engine.HasImage("some-ref:latest")).To(BeTrue())
+
+			creds := eng.RegistryCreds{Username: "some-user"}
+			var aux *eng.ProgressAux
+			for p := range engine.NewImage().Push("some-ref", creds) {
+				Expect(p.Status()).To(Equal("N/A"))
+				aux = p.Aux
+			}
+			Expect(aux.Tag).To(Equal("latest"))
+			Expect(aux.Digest).To(HavePrefix("sha256:"))
+			pushedCreds, ok := engine.Pushed("some-ref")
+			Expect(ok).To(BeTrue())
+			Expect(pushedCreds).To(Equal(creds))
+
+			Expect(engine.NewImage().Delete("some-ref")).To(Succeed())
+			Expect(engine.HasImage("some-ref")).To(BeFalse())
+			Expect(engine.NewImage().Delete("some-ref")).To(MatchError("No such image: some-ref"))
+		})
+	})
+})
+
+func testTar(files map[string][]byte) []byte {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	buffer := &bytes.Buffer{}
+	tarball := tar.NewWriter(buffer)
+	for _, name := range names {
+		Expect(tarball.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name]))})).To(Succeed())
+		_, err := tarball.Write(files[name])
+		Expect(err).NotTo(HaveOccurred())
+	}
+	Expect(tarball.Close()).To(Succeed())
+	return buffer.Bytes()
+}
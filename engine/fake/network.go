@@ -0,0 +1,107 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	eng "github.com/buildpack/forge/engine"
+)
+
+func (c *Container) Network() (*eng.NetworkInfo, error) {
+	return c.NetworkContext(context.Background())
+}
+
+func (c *Container) NetworkContext(ctx context.Context) (*eng.NetworkInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := c.checkExists(); err != nil {
+		return nil, err
+	}
+	owner := c
+	if id := c.config.NetContainer; id != "" {
+		c.engine.mutex.Lock()
+		owner = c.engine.containers[id]
+		c.engine.mutex.Unlock()
+		if owner == nil {
+			return nil, fmt.Errorf("Error: No such container: %s", id)
+		}
+	}
+	network := &eng.NetworkInfo{IPAddresses: map[string]string{}}
+	if !owner.Running() {
+		return network, nil
+	}
+	network.IPAddresses["bridge"] = owner.ip
+	network.Ports = append(network.Ports, owner.ports...)
+	return network, nil
+}
+
+func (e *Engine) assignPorts(config *eng.ContainerConfig) ([]eng.PortBinding, error) {
+	if config.NetContainer != "" {
+		return nil, nil
+	}
+	bindings := config.Ports
+	if config.Port != "" {
+		bindings = append([]eng.PortBinding{{
+			ContainerPort: config.Port,
+			HostIP:        config.HostIP,
+			HostPort:      config.HostPort,
+		}}, bindings...)
+	}
+	var ports []eng.PortBinding
+	for _, b := range bindings {
+		proto := b.Protocol
+		if proto == "" {
+			proto = "tcp"
+		}
+		start, end, err := parsePortRange(b.ContainerPort)
+		if err != nil {
+			return nil, err
+		}
+		hostStart, hostEnd := 0, 0
+		if b.HostPort != "" {
+			if hostStart, hostEnd, err = parsePortRange(b.HostPort); err != nil {
+				return nil, err
+			}
+			if end != start && hostEnd-hostStart != end-start {
+				return nil, fmt.Errorf("invalid port range: %s:%s", b.HostPort, b.ContainerPort)
+			}
+		}
+		for i := 0; i <= end-start; i++ {
+			hostPort := hostStart + i
+			if b.HostPort == "" {
+				e.lastPort++
+				hostPort = e.lastPort
+			} else if end == start {
+				hostPort = hostStart
+			}
+			hostIP := b.HostIP
+			if hostIP == "" {
+				hostIP = "0.0.0.0"
+			}
+			ports = append(ports, eng.PortBinding{
+				Protocol:      proto,
+				ContainerPort: strconv.Itoa(start + i),
+				HostIP:        hostIP,
+				HostPort:      strconv.Itoa(hostPort),
+			})
+		}
+	}
+	return ports, nil
+}
+
+func parsePortRange(ports string) (start, end int, err error) {
+	parts := strings.SplitN(ports, "-", 2)
+	if start, err = strconv.Atoi(parts[0]); err != nil {
+		return 0, 0, fmt.Errorf("invalid port: %s", ports)
+	}
+	end = start
+	if len(parts) == 2 {
+		if end, err = strconv.Atoi(parts[1]); err != nil || end < start {
+			return 0, 0, fmt.Errorf("invalid port range: %s", ports)
+		}
+	}
+	return start, end, nil
+}
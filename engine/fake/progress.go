@@ -0,0 +1,15 @@
+package fake
+
+import (
+	"errors"
+
+	eng "github.com/buildpack/forge/engine"
+)
+
+func progressError(err error) eng.Progress {
+	return eng.Progress{Err: err}
+}
+
+func progressErrorString(msg string) eng.Progress {
+	return eng.Progress{Err: errors.New(msg)}
+}
@@ -0,0 +1,28 @@
+package engine
+
+import "time"
+
+type ImageInfo struct {
+	ID           string
+	RepoTags     []string
+	RepoDigests  []string
+	Labels       map[string]string
+	Env          []string // Inspect only
+	Entrypoint   []string // Inspect only
+	Cmd          []string // Inspect only
+	WorkingDir   string   // Inspect only
+	User         string   // Inspect only
+	OS           string   // Inspect only
+	Architecture string   // Inspect only
+	Created      time.Time
+	Size         int64          // in bytes
+	Layers       []string       // Inspect only, diff IDs
+	History      []ImageHistory // Inspect only, oldest first
+}
+
+type ImageHistory struct {
+	Created    time.Time
+	CreatedBy  string
+	Comment    string
+	EmptyLayer bool
+}
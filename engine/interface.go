@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"context"
 	"io"
 	"time"
 )
@@ -8,6 +9,10 @@ import (
 type Engine interface {
 	NewContainer(config *ContainerConfig) (Container, error)
 	NewImage() Image
+	Events() <-chan Event
+	EventsContext(ctx context.Context) <-chan Event
+	GC(config *GCConfig) (orphans []Resource, err error)
+	GCContext(ctx context.Context, config *GCConfig) (orphans []Resource, err error)
 	Close() error
 }
 
@@ -16,29 +21,62 @@ type Container interface {
 	ID() string
 	CloseAfterStream(stream *Stream) error
 	Background() error
+	Stop(timeout time.Duration) error
+	StopContext(ctx context.Context, timeout time.Duration) error
+	Kill(signal string) error
+	KillContext(ctx context.Context, signal string) error
+	Pause() error
+	PauseContext(ctx context.Context) error
+	Unpause() error
+	UnpauseContext(ctx context.Context) error
 	Start(logPrefix string, logs io.Writer, restart <-chan time.Time) (status int64, err error)
+	StartContext(ctx context.Context, logPrefix string, logs io.Writer, restart <-chan time.Time) (status int64, err error)
 	Shell(tty TTY, shell ...string) (err error)
+	ShellContext(ctx context.Context, tty TTY, shell ...string) (err error)
+	Exec(config *ExecConfig) (status int64, err error)
+	ExecContext(ctx context.Context, config *ExecConfig) (status int64, err error)
 	HealthCheck() <-chan string
+	HealthCheckContext(ctx context.Context) <-chan string
+	Stats() <-chan Stats
+	StatsContext(ctx context.Context) <-chan Stats
+	Network() (*NetworkInfo, error)
+	NetworkContext(ctx context.Context) (*NetworkInfo, error)
 	Commit(ref string) (imageID string, err error)
+	CommitContext(ctx context.Context, ref string) (imageID string, err error)
 	UploadTarTo(tar io.Reader, path string) error
+	UploadTarToContext(ctx context.Context, tar io.Reader, path string) error
 	StreamFileTo(stream Stream, path string) error
 	StreamTarTo(stream Stream, path string) error
 	StreamFileFrom(path string) (Stream, error)
+	StreamFileFromContext(ctx context.Context, path string) (Stream, error)
 	StreamTarFrom(path string) (Stream, error)
 	Mkdir(path string) error
 }
 
 type Image interface {
 	Build(tag string, dockerfile Stream) <-chan Progress
+	BuildContext(ctx context.Context, tag string, dockerfile Stream) <-chan Progress
+	BuildFrom(config *BuildConfig) <-chan Progress
+	BuildFromContext(ctx context.Context, config *BuildConfig) <-chan Progress
 	Pull(ref string) <-chan Progress
+	PullContext(ctx context.Context, ref string) <-chan Progress
 	Push(ref string, creds RegistryCreds) <-chan Progress
+	PushContext(ctx context.Context, ref string, creds RegistryCreds) <-chan Progress
+	Save(progress chan<- Progress, refs ...string) (Stream, error)
+	SaveContext(ctx context.Context, progress chan<- Progress, refs ...string) (Stream, error)
+	Load(image Stream) <-chan Progress
+	LoadContext(ctx context.Context, image Stream) <-chan Progress
+	Inspect(ref string) (*ImageInfo, error)
+	InspectContext(ctx context.Context, ref string) (*ImageInfo, error)
+	List(labels map[string]string) ([]ImageInfo, error)
+	ListContext(ctx context.Context, labels map[string]string) ([]ImageInfo, error)
+	Tag(ref, tag string) error
+	TagContext(ctx context.Context, ref, tag string) error
+	Exists(ref string) (bool, error)
+	ExistsContext(ctx context.Context, ref string) (bool, error)
 	Delete(id string) error
 }
 
 type TTY interface {
 	Run(remoteIn io.Reader, remoteOut io.WriteCloser, resize func(w, h uint16) error) error
 }
-
-type Progress interface {
-	Status() (string, error)
-}
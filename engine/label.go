@@ -0,0 +1,50 @@
+package engine
+
+import "time"
+
+const (
+	LabelSession  = "io.buildpack.forge.session"
+	LabelRole     = "io.buildpack.forge.role"
+	LabelApp      = "io.buildpack.forge.app"
+	LabelCreated  = "io.buildpack.forge.created"
+	LabelMetadata = "io.buildpack.forge.metadata" // exported images only, JSON
+	LabelHost     = "io.buildpack.forge.host"     // containers only
+	LabelPID      = "io.buildpack.forge.pid"      // containers only
+)
+
+const (
+	RoleStaging = "staging"
+	RoleRun     = "run"
+	RoleNetwork = "network"
+	RoleTunnel  = "tunnel"
+	RoleExport  = "export"
+	RoleBuild   = "build"
+)
+
+type ResourceType string
+
+const (
+	ContainerResource ResourceType = "container"
+	ImageResource     ResourceType = "image"
+)
+
+type Resource struct {
+	Type    ResourceType
+	ID      string
+	Session string
+	Role    string
+	AppName string
+	Created time.Time
+}
+
+func NewResource(kind ResourceType, id string, labels map[string]string) Resource {
+	created, _ := time.Parse(time.RFC3339Nano, labels[LabelCreated])
+	return Resource{
+		Type:    kind,
+		ID:      id,
+		Session: labels[LabelSession],
+		Role:    labels[LabelRole],
+		AppName: labels[LabelApp],
+		Created: created,
+	}
+}
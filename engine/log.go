@@ -0,0 +1,22 @@
+package engine
+
+import "time"
+
+type LogStream string
+
+const (
+	Stdout LogStream = "stdout"
+	Stderr LogStream = "stderr"
+)
+
+type LogRecord struct {
+	Stream      LogStream
+	Time        time.Time
+	ContainerID string
+	AppName     string
+	Message     string
+}
+
+type LogSink interface {
+	Log(record *LogRecord) error
+}
@@ -0,0 +1,6 @@
+package engine
+
+type NetworkInfo struct {
+	IPAddresses map[string]string // by network name
+	Ports       []PortBinding     // host ports assigned by engine
+}
@@ -0,0 +1,154 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	progressInterval = 100 * time.Millisecond
+	progressBarWidth = 50
+)
+
+type Progress struct {
+	ID      string        // layer ID, or stream ID for NewProgressStream
+	Message string        // e.g. Downloading, Pushed, Transferring
+	Bar     string        // rendered progress bar
+	Current int64         // in bytes
+	Total   int64         // in bytes, zero if unknown
+	Rate    int64         // in bytes per second, streams only
+	ETA     time.Duration // estimated time remaining, streams only
+	Stream  string        // build output
+	Aux     *ProgressAux
+	Err     error
+}
+
+type ProgressAux struct {
+	ImageID string // build and load only
+	Tag     string // push only
+	Digest  string // push only
+	Size    int64  // push only, in bytes
+}
+
+func (p Progress) Status() (string, error) {
+	if p.Err != nil {
+		return "", p.Err
+	}
+	if p.Bar != "" {
+		return p.Bar, nil
+	}
+	return "N/A", nil
+}
+
+// NewProgressStream sends the progress of a stream to progress as it is read,
+// and a final update when it is closed. Updates are skipped while progress is not
+// ready to receive them, so progress should be buffered to reliably receive the final update.
+// Progress must not be closed before the stream. A nil progress channel returns the stream unchanged.
+func NewProgressStream(stream Stream, id string, progress chan<- Progress) Stream {
+	if progress == nil {
+		return stream
+	}
+	total := stream.Remaining()
+	if total < 0 {
+		total = 0
+	}
+	return Stream{
+		&progressReader{
+			ReadCloser: stream.ReadCloser,
+			id:         id,
+			total:      total,
+			progress:   progress,
+			start:      time.Now(),
+		},
+		stream.StreamState,
+	}
+}
+
+type progressReader struct {
+	io.ReadCloser
+	id       string
+	total    int64
+	current  int64
+	progress chan<- Progress
+	start    time.Time
+	last     time.Time
+	closed   bool
+}
+
+func (p *progressReader) Read(b []byte) (n int, err error) {
+	n, err = p.ReadCloser.Read(b)
+	p.current += int64(n)
+	if now := time.Now(); now.Sub(p.last) >= progressInterval {
+		p.last = now
+		select {
+		case p.progress <- p.update(now, "Transferring"):
+		default:
+		}
+	}
+	return n, err
+}
+
+func (p *progressReader) Close() error {
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	err := p.ReadCloser.Close()
+	select {
+	case p.progress <- p.update(time.Now(), "Transferred"):
+	default:
+	}
+	return err
+}
+
+func (p *progressReader) update(now time.Time, message string) Progress {
+	var rate int64
+	if elapsed := now.Sub(p.start); elapsed > 0 {
+		rate = int64(float64(p.current) / elapsed.Seconds())
+	}
+	var eta time.Duration
+	if rate > 0 && p.total > p.current {
+		eta = time.Duration(float64(p.total-p.current) / float64(rate) * float64(time.Second))
+	}
+	return Progress{
+		ID:      p.id,
+		Message: message,
+		Bar:     progressBar(p.current, p.total, eta),
+		Current: p.current,
+		Total:   p.total,
+		Rate:    rate,
+		ETA:     eta,
+	}
+}
+
+// progressBar renders progress like the docker CLI, e.g. [=====>    ] 1.5MB/3MB 2s
+func progressBar(current, total int64, eta time.Duration) string {
+	if total <= 0 {
+		return humanSize(current)
+	}
+	filled := int(progressBarWidth * current / total)
+	if filled > progressBarWidth {
+		filled = progressBarWidth
+	}
+	bar := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+	s := fmt.Sprintf("[%s] %s/%s", bar, humanSize(current), humanSize(total))
+	if eta = eta.Round(time.Second); eta > 0 {
+		s += " " + eta.String()
+	}
+	return s
+}
+
+func humanSize(size int64) string {
+	units := []string{"B", "kB", "MB", "GB", "TB"}
+	value, i := float64(size), 0
+	for value >= 1000 && i < len(units)-1 {
+		value /= 1000
+		i++
+	}
+	return fmt.Sprintf("%.4g%s", value, units[i])
+}
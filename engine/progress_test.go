@@ -0,0 +1,96 @@
+package engine_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/buildpack/forge/engine"
+)
+
+var _ = Describe("Progress", func() {
+	Describe("#NewProgressStream", func() {
+		var progress chan Progress
+
+		BeforeEach(func() {
+			progress = make(chan Progress, 10)
+		})
+
+		It("should report progress as the stream is read and when it is closed", func() {
+			data := strings.Repeat("a", 2000)
+			stream := NewProgressStream(NewStream(ioutil.NopCloser(bytes.NewBufferString(data)), 2000), "some-id", progress)
+			buf := make([]byte, 500)
+			Expect(stream.Read(buf)).To(Equal(500))
+
+			var p Progress
+			Eventually(progress).Should(Receive(&p))
+			Expect(p.ID).To(Equal("some-id"))
+			Expect(p.Message).To(Equal("Transferring"))
+			Expect(p.Current).To(Equal(int64(500)))
+			Expect(p.Total).To(Equal(int64(2000)))
+			Expect(p.Bar).To(HavePrefix("[============>                                     ] 500B/2kB"))
+
+			Expect(ioutil.ReadAll(stream)).To(HaveLen(1500))
+			Expect(stream.Close()).To(Succeed())
+			Expect(stream.Close()).To(Succeed())
+			close(progress)
+
+			var last Progress
+			for p := range progress {
+				last = p
+			}
+			Expect(last.Message).To(Equal("Transferred"))
+			Expect(last.Current).To(Equal(int64(2000)))
+			Expect(last.Bar).To(Equal("[==================================================] 2kB/2kB"))
+			Expect(last.ETA).To(BeZero())
+		})
+
+		It("should report the size transferred for streams of unknown size", func() {
+			stream := NewProgressStream(NewStream(ioutil.NopCloser(bytes.NewBufferString("some-data")), -1), "some-id", progress)
+			Expect(ioutil.ReadAll(stream)).To(Equal([]byte("some-data")))
+			Expect(stream.Close()).To(Succeed())
+			close(progress)
+
+			var last Progress
+			for p := range progress {
+				last = p
+			}
+			Expect(last.Current).To(Equal(int64(9)))
+			Expect(last.Total).To(BeZero())
+			Expect(last.Bar).To(Equal("9B"))
+		})
+
+		It("should report the remainder of a partially read stream", func() {
+			stream := NewStream(ioutil.NopCloser(bytes.NewBufferString("some-data")), 9)
+			Expect(stream.Read(make([]byte, 5))).To(Equal(5))
+			stream = NewProgressStream(stream, "some-id", progress)
+			Expect(ioutil.ReadAll(stream)).To(Equal([]byte("data")))
+			Expect(stream.Close()).To(Succeed())
+
+			var p Progress
+			Eventually(progress).Should(Receive(&p))
+			Expect(p.Current).To(Equal(int64(4)))
+			Expect(p.Total).To(Equal(int64(4)))
+		})
+
+		It("should not block when closed while progress is not ready", func() {
+			stream := NewProgressStream(NewStream(ioutil.NopCloser(bytes.NewBufferString("some-data")), 9), "some-id", make(chan Progress))
+			Expect(ioutil.ReadAll(stream)).To(Equal([]byte("some-data")))
+			Expect(stream.Close()).To(Succeed())
+		})
+
+		It("should keep the digest of the stream", func() {
+			stream := NewProgressStream(NewDigestStream(NewStream(ioutil.NopCloser(bytes.NewBufferString("some-data")), 9), ""), "some-id", progress)
+			Expect(ioutil.ReadAll(stream)).To(Equal([]byte("some-data")))
+			Expect(stream.Digest()).To(Equal("sha256:9332d94d5ee69ad17d310e62cd101d70f578024fd5e8d1647f8073f886c894e1"))
+		})
+
+		It("should return the stream unchanged without a progress channel", func() {
+			stream := NewStream(ioutil.NopCloser(bytes.NewBufferString("some-data")), 9)
+			Expect(NewProgressStream(stream, "some-id", nil)).To(Equal(stream))
+		})
+	})
+})
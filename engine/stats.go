@@ -0,0 +1,16 @@
+package engine
+
+import "time"
+
+type Stats struct {
+	Time        time.Time
+	CPUPercent  float64
+	MemoryUsage uint64 // in bytes
+	MemoryLimit uint64 // in bytes
+	NetworkRx   uint64 // in bytes
+	NetworkTx   uint64 // in bytes
+	BlockRead   uint64 // in bytes
+	BlockWrite  uint64 // in bytes
+	PIDs        uint64
+	Err         error // set on the last sample if reading stats failed
+}
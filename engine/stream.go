@@ -1,8 +1,12 @@
 package engine
 
 import (
+	"crypto/sha256"
 	"errors"
+	"fmt"
+	"hash"
 	"io"
+	"strings"
 )
 
 type Stream struct {
@@ -10,28 +14,147 @@ type Stream struct {
 	*StreamState
 }
 
+// StreamState is shared by all copies of a Stream.
 type StreamState struct {
-	Size   int64
-	closed bool
+	Size     int64 // negative if unknown
+	Position int64 // bytes read so far
+	closed   bool
+	digest   hash.Hash
 }
 
 func NewStream(data io.ReadCloser, size int64) Stream {
 	return Stream{
 		data,
-		&StreamState{size, false},
+		&StreamState{Size: size},
 	}
 }
 
+// NewDigestStream computes the SHA-256 digest of a stream as it is read.
+// If expected is set, Close and Out fail with a *DigestError when all data was read and the digests differ.
+func NewDigestStream(stream Stream, expected string) Stream {
+	if expected != "" && !strings.HasPrefix(expected, "sha256:") {
+		expected = "sha256:" + expected
+	}
+	digest := sha256.New()
+	return Stream{
+		&digestReader{
+			ReadCloser: stream.ReadCloser,
+			hash:       digest,
+			expected:   strings.ToLower(expected),
+			remaining:  stream.Remaining(),
+		},
+		&StreamState{Size: stream.Remaining(), digest: digest},
+	}
+}
+
+type DigestError struct {
+	Expected string
+	Actual   string
+}
+
+func (e *DigestError) Error() string {
+	return fmt.Sprintf("digest mismatch: expected %s, got %s", e.Expected, e.Actual)
+}
+
+// Digest returns the digest of the data read so far from a stream created by NewDigestStream.
+func (s Stream) Digest() string {
+	if s.StreamState == nil || s.digest == nil {
+		return ""
+	}
+	return fmt.Sprintf("sha256:%x", s.digest.Sum(nil))
+}
+
+func (s Stream) Read(p []byte) (n int, err error) {
+	n, err = s.ReadCloser.Read(p)
+	s.Position += int64(n)
+	return n, err
+}
+
+// Remaining returns the number of bytes left to read, or a negative number if the size is unknown.
+func (s Stream) Remaining() int64 {
+	if s.Size < 0 {
+		return s.Size
+	}
+	return s.Size - s.Position
+}
+
 func (s Stream) Out(dst io.Writer) error {
 	if s.closed {
 		return errors.New("closed")
 	}
-	defer s.Close()
-	n, err := io.CopyN(dst, s, s.Size)
-	s.Size -= n
+	var err error
+	if remaining := s.Remaining(); remaining < 0 {
+		_, err = io.Copy(dst, s)
+	} else {
+		_, err = io.CopyN(dst, s, remaining)
+	}
+	if closeErr := s.Close(); err == nil {
+		err = closeErr
+	}
 	return err
 }
 
+// Tee returns a stream that writes everything read from s to w.
+// Closing the returned stream closes s.
+func (s Stream) Tee(w io.Writer) Stream {
+	return NewStream(struct {
+		io.Reader
+		io.Closer
+	}{io.TeeReader(s, w), s}, s.Remaining())
+}
+
+// Split returns n streams that each read the remaining data of s, which must be read concurrently.
+// Data is read from s as fast as the slowest of the streams that are open.
+// When the data ends or all of the streams are closed, s is closed and any close error
+// (such as a *DigestError) is returned by the open streams in place of io.EOF.
+func (s Stream) Split(n int) []Stream {
+	streams := make([]Stream, n)
+	writers := make([]*io.PipeWriter, n)
+	for i := range streams {
+		reader, writer := io.Pipe()
+		streams[i] = NewStream(reader, s.Remaining())
+		writers[i] = writer
+	}
+	var data io.Reader = s
+	if remaining := s.Remaining(); remaining >= 0 {
+		data = io.LimitReader(s, remaining)
+	}
+	go func() {
+		buf := make([]byte, 32*1024)
+		open := n
+		for open > 0 {
+			m, err := data.Read(buf)
+			for i, w := range writers {
+				if w == nil || m == 0 {
+					continue
+				}
+				if _, err := w.Write(buf[:m]); err != nil {
+					writers[i] = nil
+					open--
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				closeAll(writers, err)
+				s.Close()
+				return
+			}
+		}
+		closeAll(writers, s.Close())
+	}()
+	return streams
+}
+
+func closeAll(writers []*io.PipeWriter, err error) {
+	for _, w := range writers {
+		if w != nil {
+			w.CloseWithError(err)
+		}
+	}
+}
+
 func (s Stream) Close() error {
 	if s.closed {
 		return nil
@@ -42,3 +165,40 @@ func (s Stream) Close() error {
 	s.closed = true
 	return nil
 }
+
+type digestReader struct {
+	io.ReadCloser
+	hash      hash.Hash
+	expected  string
+	remaining int64 // negative if unknown
+	eof       bool
+	closed    bool
+	err       error
+}
+
+func (d *digestReader) Read(p []byte) (n int, err error) {
+	n, err = d.ReadCloser.Read(p)
+	d.hash.Write(p[:n])
+	d.remaining -= int64(n)
+	if err == io.EOF {
+		d.eof = true
+	}
+	return n, err
+}
+
+func (d *digestReader) Close() error {
+	if d.closed {
+		return d.err
+	}
+	if err := d.ReadCloser.Close(); err != nil {
+		return err
+	}
+	d.closed = true
+	if d.expected == "" || !d.eof && d.remaining != 0 {
+		return nil
+	}
+	if actual := fmt.Sprintf("sha256:%x", d.hash.Sum(nil)); actual != d.expected {
+		d.err = &DigestError{Expected: d.expected, Actual: actual}
+	}
+	return d.err
+}
@@ -0,0 +1,143 @@
+package engine_test
+
+import (
+	"bytes"
+	"io/ioutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/buildpack/forge/engine"
+)
+
+var _ = Describe("Stream", func() {
+	const someDataDigest = "sha256:9332d94d5ee69ad17d310e62cd101d70f578024fd5e8d1647f8073f886c894e1"
+
+	newStream := func(data string) Stream {
+		return NewStream(ioutil.NopCloser(bytes.NewBufferString(data)), int64(len(data)))
+	}
+
+	Describe("#Read", func() {
+		It("should track the position on state shared by copies of the stream", func() {
+			stream := newStream("some-data")
+			streamCopy := stream
+			Expect(streamCopy.Read(make([]byte, 4))).To(Equal(4))
+			Expect(stream.Size).To(Equal(int64(9)))
+			Expect(stream.Position).To(Equal(int64(4)))
+			Expect(stream.Remaining()).To(Equal(int64(5)))
+		})
+
+		It("should report an unknown remaining size for streams of unknown size", func() {
+			stream := NewStream(ioutil.NopCloser(bytes.NewBufferString("some-data")), -1)
+			Expect(ioutil.ReadAll(stream)).To(Equal([]byte("some-data")))
+			Expect(stream.Position).To(Equal(int64(9)))
+			Expect(stream.Remaining()).To(BeNumerically("<", 0))
+		})
+	})
+
+	Describe("#Out", func() {
+		It("should copy the stream and close it", func() {
+			out := &bytes.Buffer{}
+			stream := newStream("some-data")
+			Expect(stream.Out(out)).To(Succeed())
+			Expect(out.String()).To(Equal("some-data"))
+			Expect(stream.Position).To(Equal(int64(9)))
+			Expect(stream.Out(out)).To(MatchError("closed"))
+		})
+
+		It("should copy the remaining data of a partially read stream", func() {
+			out := &bytes.Buffer{}
+			stream := NewStream(ioutil.NopCloser(bytes.NewBufferString("some-data-and-more")), 9)
+			Expect(stream.Read(make([]byte, 5))).To(Equal(5))
+			Expect(stream.Out(out)).To(Succeed())
+			Expect(out.String()).To(Equal("data"))
+		})
+
+		It("should copy streams of unknown size to the end", func() {
+			out := &bytes.Buffer{}
+			stream := NewStream(ioutil.NopCloser(bytes.NewBufferString("some-data")), -1)
+			Expect(stream.Out(out)).To(Succeed())
+			Expect(out.String()).To(Equal("some-data"))
+		})
+	})
+
+	Describe("#Tee", func() {
+		It("should write the data read from the stream to the writer", func() {
+			out := &bytes.Buffer{}
+			stream := newStream("some-data")
+			tee := stream.Tee(out)
+			Expect(tee.Size).To(Equal(int64(9)))
+			Expect(ioutil.ReadAll(tee)).To(Equal([]byte("some-data")))
+			Expect(out.String()).To(Equal("some-data"))
+			Expect(stream.Position).To(Equal(int64(9)))
+			Expect(tee.Close()).To(Succeed())
+			Expect(stream.Out(out)).To(MatchError("closed"))
+		})
+	})
+
+	Describe("#Split", func() {
+		It("should return streams that each read all of the data", func() {
+			streams := newStream("some-data").Split(2)
+			Expect(streams).To(HaveLen(2))
+			out := &bytes.Buffer{}
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				Expect(streams[0].Out(out)).To(Succeed())
+			}()
+			Expect(ioutil.ReadAll(streams[1])).To(Equal([]byte("some-data")))
+			Expect(streams[1].Close()).To(Succeed())
+			Eventually(done).Should(BeClosed())
+			Expect(out.String()).To(Equal("some-data"))
+			Expect(streams[0].Size).To(Equal(int64(9)))
+		})
+
+		It("should continue after one of the streams is closed", func() {
+			streams := newStream("some-data").Split(2)
+			Expect(streams[0].Close()).To(Succeed())
+			Expect(ioutil.ReadAll(streams[1])).To(Equal([]byte("some-data")))
+		})
+
+		It("should return errors from closing the stream to the split streams", func() {
+			streams := NewDigestStream(newStream("some-other-data"), someDataDigest).Split(1)
+			_, err := ioutil.ReadAll(streams[0])
+			Expect(err).To(BeAssignableToTypeOf(&DigestError{}))
+		})
+	})
+
+	Describe("#NewDigestStream", func() {
+		It("should compute the digest of the data read", func() {
+			stream := NewDigestStream(newStream("some-data"), "")
+			Expect(stream.Digest()).To(Equal("sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"))
+			Expect(ioutil.ReadAll(stream)).To(Equal([]byte("some-data")))
+			Expect(stream.Digest()).To(Equal(someDataDigest))
+			Expect(stream.Close()).To(Succeed())
+		})
+
+		It("should succeed when the data matches the expected digest", func() {
+			out := &bytes.Buffer{}
+			stream := NewDigestStream(newStream("some-data"), someDataDigest[len("sha256:"):])
+			Expect(stream.Out(out)).To(Succeed())
+			Expect(out.String()).To(Equal("some-data"))
+		})
+
+		It("should fail when the data does not match the expected digest", func() {
+			stream := NewDigestStream(newStream("some-other-data"), someDataDigest)
+			err := stream.Out(&bytes.Buffer{})
+			Expect(err).To(BeAssignableToTypeOf(&DigestError{}))
+			Expect(err.(*DigestError).Expected).To(Equal(someDataDigest))
+			Expect(err).To(MatchError(HavePrefix("digest mismatch: expected " + someDataDigest + ", got sha256:")))
+		})
+
+		It("should not verify streams that were closed before all data was read", func() {
+			stream := NewDigestStream(newStream("some-other-data"), someDataDigest)
+			Expect(stream.Close()).To(Succeed())
+		})
+
+		It("should verify streams of unknown size when they are read to the end", func() {
+			stream := NewDigestStream(NewStream(ioutil.NopCloser(bytes.NewBufferString("some-other-data")), -1), someDataDigest)
+			Expect(ioutil.ReadAll(stream)).To(Equal([]byte("some-other-data")))
+			Expect(stream.Close()).To(BeAssignableToTypeOf(&DigestError{}))
+		})
+	})
+})
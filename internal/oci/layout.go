@@ -0,0 +1,222 @@
+package oci
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	"github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Layout is the path to an OCI image layout directory.
+type Layout string
+
+type Image struct {
+	Manifest v1.Manifest
+	Config   v1.Image
+}
+
+// Image reads the image tagged ref, or the only image in the layout if ref is empty.
+func (l Layout) Image(ref string) (*Image, error) {
+	var index v1.Index
+	if err := readJSON(filepath.Join(string(l), "index.json"), &index); err != nil {
+		return nil, err
+	}
+	var manifests []v1.Descriptor
+	for _, desc := range index.Manifests {
+		if desc.MediaType != v1.MediaTypeImageManifest {
+			continue
+		}
+		if ref == "" || desc.Annotations[v1.AnnotationRefName] == ref {
+			manifests = append(manifests, desc)
+		}
+	}
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("image %s not found in %s", ref, l)
+	}
+	if len(manifests) > 1 {
+		return nil, fmt.Errorf("multiple images found in %s", l)
+	}
+	image := &Image{}
+	if err := readJSON(l.blobPath(manifests[0].Digest), &image.Manifest); err != nil {
+		return nil, err
+	}
+	if err := readJSON(l.blobPath(image.Manifest.Config.Digest), &image.Config); err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+func (l Layout) Open(desc v1.Descriptor) (io.ReadCloser, error) {
+	return os.Open(l.blobPath(desc.Digest))
+}
+
+func (l Layout) Has(desc v1.Descriptor) bool {
+	_, err := os.Stat(l.blobPath(desc.Digest))
+	return err == nil
+}
+
+func (l Layout) CopyBlob(src Layout, desc v1.Descriptor) error {
+	if l.Has(desc) {
+		return nil
+	}
+	blob, err := src.Open(desc)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+	copied, err := l.WriteBlob(desc.MediaType, blob)
+	if err != nil {
+		return err
+	}
+	if copied.Digest != desc.Digest {
+		return fmt.Errorf("digest mismatch for %s: got %s", desc.Digest, copied.Digest)
+	}
+	return nil
+}
+
+func (l Layout) WriteBlob(mediaType string, blob io.Reader) (v1.Descriptor, error) {
+	dir := filepath.Join(string(l), "blobs", string(digest.Canonical))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return v1.Descriptor{}, err
+	}
+	tmp, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	digester := digest.Canonical.Digester()
+	size, err := io.Copy(io.MultiWriter(tmp, digester.Hash()), blob)
+	if err != nil {
+		return v1.Descriptor{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return v1.Descriptor{}, err
+	}
+	desc := v1.Descriptor{
+		MediaType: mediaType,
+		Digest:    digester.Digest(),
+		Size:      size,
+	}
+	return desc, os.Rename(tmp.Name(), l.blobPath(desc.Digest))
+}
+
+func (l Layout) WriteJSON(mediaType string, v interface{}) (v1.Descriptor, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(json.NewEncoder(pw).Encode(v))
+	}()
+	defer pr.Close()
+	return l.WriteBlob(mediaType, pr)
+}
+
+// WriteLayer compresses an uncompressed layer tarball into the layout.
+func (l Layout) WriteLayer(layer io.Reader) (desc v1.Descriptor, diffID digest.Digest, err error) {
+	digester := digest.Canonical.Digester()
+	pr, pw := io.Pipe()
+	go func() {
+		gz := gzip.NewWriter(pw)
+		if _, err := io.Copy(gz, io.TeeReader(layer, digester.Hash())); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(gz.Close())
+	}()
+	defer pr.Close()
+	desc, err = l.WriteBlob(v1.MediaTypeImageLayerGzip, pr)
+	return desc, digester.Digest(), err
+}
+
+// Tag adds the manifest to the layout index, replacing any manifest already tagged ref.
+func (l Layout) Tag(manifest v1.Descriptor, ref string) error {
+	layoutJSON, err := json.Marshal(v1.ImageLayout{Version: v1.ImageLayoutVersion})
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(string(l), v1.ImageLayoutFile), layoutJSON, 0644); err != nil {
+		return err
+	}
+	index := v1.Index{Versioned: specs.Versioned{SchemaVersion: 2}}
+	indexPath := filepath.Join(string(l), "index.json")
+	if _, err := os.Stat(indexPath); err == nil {
+		if err := readJSON(indexPath, &index); err != nil {
+			return err
+		}
+	}
+	manifests := index.Manifests[:0]
+	for _, desc := range index.Manifests {
+		if ref == "" || desc.Annotations[v1.AnnotationRefName] != ref {
+			manifests = append(manifests, desc)
+		}
+	}
+	if ref != "" {
+		manifest.Annotations = map[string]string{v1.AnnotationRefName: ref}
+	}
+	index.Manifests = append(manifests, manifest)
+	indexJSON, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(indexPath, indexJSON, 0644)
+}
+
+// Archive writes the layout as an oci-archive tarball.
+func (l Layout) Archive(w io.Writer) error {
+	tarball := tar.NewWriter(w)
+	root := string(l)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == root {
+			return err
+		}
+		name, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tarball.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tarball, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tarball.Close()
+}
+
+func (l Layout) blobPath(d digest.Digest) string {
+	return filepath.Join(string(l), "blobs", string(d.Algorithm()), d.Hex())
+}
+
+func readJSON(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,222 @@
+package oci_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	"github.com/opencontainers/image-spec/specs-go/v1"
+
+	. "github.com/buildpack/forge/internal/oci"
+)
+
+var _ = Describe("Layout", func() {
+	var (
+		tmpDir string
+		layout Layout
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "forge.oci.test")
+		Expect(err).NotTo(HaveOccurred())
+		layout = Layout(filepath.Join(tmpDir, "layout"))
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	writeImage := func(layout Layout, ref string, layer []byte) (manifest, config, layerDesc v1.Descriptor, diffID digest.Digest) {
+		var err error
+		layerDesc, diffID, err = layout.WriteLayer(bytes.NewReader(layer))
+		Expect(err).NotTo(HaveOccurred())
+		config, err = layout.WriteJSON(v1.MediaTypeImageConfig, v1.Image{
+			OS:           "linux",
+			Architecture: "amd64",
+			RootFS:       v1.RootFS{Type: "layers", DiffIDs: []digest.Digest{diffID}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		manifest, err = layout.WriteJSON(v1.MediaTypeImageManifest, v1.Manifest{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+			Config:    config,
+			Layers:    []v1.Descriptor{layerDesc},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(layout.Tag(manifest, ref)).To(Succeed())
+		return manifest, config, layerDesc, diffID
+	}
+
+	readIndex := func(layout Layout) v1.Index {
+		data, err := ioutil.ReadFile(filepath.Join(string(layout), "index.json"))
+		Expect(err).NotTo(HaveOccurred())
+		var index v1.Index
+		Expect(json.Unmarshal(data, &index)).To(Succeed())
+		return index
+	}
+
+	Describe("#WriteBlob / #WriteJSON / #WriteLayer / #Tag / #Image", func() {
+		It("should write an image that can be read back by ref", func() {
+			layer := testTar("some-file", "some-data")
+			manifest, config, layerDesc, diffID := writeImage(layout, "some-ref", layer)
+
+			Expect(diffID).To(Equal(digest.FromBytes(layer)))
+			Expect(layerDesc.MediaType).To(Equal(v1.MediaTypeImageLayerGzip))
+			Expect(layout.Has(layerDesc)).To(BeTrue())
+			blob, err := layout.Open(layerDesc)
+			Expect(err).NotTo(HaveOccurred())
+			defer blob.Close()
+			compressed, err := ioutil.ReadAll(blob)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(digest.FromBytes(compressed)).To(Equal(layerDesc.Digest))
+			Expect(int64(len(compressed))).To(Equal(layerDesc.Size))
+			gz, err := gzip.NewReader(bytes.NewReader(compressed))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ioutil.ReadAll(gz)).To(Equal(layer))
+
+			image, err := layout.Image("some-ref")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(image.Manifest.Config).To(Equal(config))
+			Expect(image.Manifest.Layers).To(Equal([]v1.Descriptor{layerDesc}))
+			Expect(image.Config.OS).To(Equal("linux"))
+			Expect(image.Config.RootFS.DiffIDs).To(Equal([]digest.Digest{diffID}))
+
+			index := readIndex(layout)
+			Expect(index.SchemaVersion).To(Equal(2))
+			Expect(index.Manifests).To(HaveLen(1))
+			Expect(index.Manifests[0].Digest).To(Equal(manifest.Digest))
+			Expect(index.Manifests[0].Size).To(Equal(manifest.Size))
+			Expect(index.Manifests[0].Annotations).To(Equal(map[string]string{v1.AnnotationRefName: "some-ref"}))
+
+			layoutJSON, err := ioutil.ReadFile(filepath.Join(string(layout), v1.ImageLayoutFile))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(layoutJSON).To(MatchJSON(`{"imageLayoutVersion": "1.0.0"}`))
+		})
+
+		It("should replace the manifest tagged with the same ref", func() {
+			writeImage(layout, "some-ref", testTar("some-file", "some-data"))
+			other, _, _, _ := writeImage(layout, "some-other-ref", testTar("some-file", "some-other-data"))
+			replaced, _, replacedLayer, _ := writeImage(layout, "some-ref", testTar("some-file", "some-new-data"))
+
+			index := readIndex(layout)
+			Expect(index.Manifests).To(HaveLen(2))
+			Expect(index.Manifests[0].Digest).To(Equal(other.Digest))
+			Expect(index.Manifests[1].Digest).To(Equal(replaced.Digest))
+
+			image, err := layout.Image("some-ref")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(image.Manifest.Layers).To(Equal([]v1.Descriptor{replacedLayer}))
+			_, err = layout.Image("")
+			Expect(err).To(MatchError("multiple images found in " + string(layout)))
+		})
+
+		It("should read the only image when no ref is given", func() {
+			manifest, config, _, _ := writeImage(layout, "", testTar("some-file", "some-data"))
+			image, err := layout.Image("")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(image.Manifest.Config).To(Equal(config))
+
+			index := readIndex(layout)
+			Expect(index.Manifests).To(HaveLen(1))
+			Expect(index.Manifests[0].Digest).To(Equal(manifest.Digest))
+			Expect(index.Manifests[0].Annotations).To(BeEmpty())
+		})
+
+		It("should return an error when the ref is not found", func() {
+			writeImage(layout, "some-ref", testTar("some-file", "some-data"))
+			_, err := layout.Image("some-missing-ref")
+			Expect(err).To(MatchError("image some-missing-ref not found in " + string(layout)))
+		})
+	})
+
+	Describe("#CopyBlob", func() {
+		It("should copy a blob from another layout", func() {
+			_, _, layerDesc, _ := writeImage(layout, "some-ref", testTar("some-file", "some-data"))
+			dst := Layout(filepath.Join(tmpDir, "dst"))
+			Expect(dst.Has(layerDesc)).To(BeFalse())
+			Expect(dst.CopyBlob(layout, layerDesc)).To(Succeed())
+			Expect(dst.Has(layerDesc)).To(BeTrue())
+			Expect(dst.CopyBlob(layout, layerDesc)).To(Succeed())
+		})
+
+		It("should return an error when the blob does not match its digest", func() {
+			desc, err := layout.WriteBlob("some-type", bytes.NewBufferString("some-data"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(desc.Digest).To(Equal(digest.FromString("some-data")))
+			Expect(desc.Size).To(Equal(int64(len("some-data"))))
+			Expect(ioutil.WriteFile(filepath.Join(string(layout), "blobs", "sha256", desc.Digest.Hex()), []byte("some-other-data"), 0644)).To(Succeed())
+
+			dst := Layout(filepath.Join(tmpDir, "dst"))
+			err = dst.CopyBlob(layout, desc)
+			Expect(err).To(MatchError("digest mismatch for " + desc.Digest.String() + ": got " + digest.FromString("some-other-data").String()))
+			Expect(dst.Has(desc)).To(BeFalse())
+		})
+	})
+
+	Describe("#Archive", func() {
+		It("should write the layout as an oci-archive tarball", func() {
+			manifest, config, layerDesc, _ := writeImage(layout, "some-ref", testTar("some-file", "some-data"))
+
+			buffer := &bytes.Buffer{}
+			Expect(layout.Archive(buffer)).To(Succeed())
+
+			files := map[string][]byte{}
+			tarball := tar.NewReader(buffer)
+			for {
+				header, err := tarball.Next()
+				if err == io.EOF {
+					break
+				}
+				Expect(err).NotTo(HaveOccurred())
+				data, err := ioutil.ReadAll(tarball)
+				Expect(err).NotTo(HaveOccurred())
+				files[header.Name] = data
+			}
+			blob := func(desc v1.Descriptor) string {
+				return "blobs/sha256/" + desc.Digest.Hex()
+			}
+			Expect(files).To(HaveLen(7))
+			Expect(files).To(HaveKey("blobs/"))
+			Expect(files).To(HaveKey("blobs/sha256/"))
+			Expect(files).To(HaveKey(v1.ImageLayoutFile))
+			Expect(files).To(HaveKey("index.json"))
+			for _, desc := range []v1.Descriptor{manifest, config, layerDesc} {
+				Expect(files).To(HaveKey(blob(desc)))
+				Expect(digest.FromBytes(files[blob(desc)])).To(Equal(desc.Digest))
+			}
+
+			extracted := Layout(filepath.Join(tmpDir, "extracted"))
+			for name, data := range files {
+				path := filepath.Join(string(extracted), filepath.FromSlash(name))
+				if name[len(name)-1] == '/' {
+					Expect(os.MkdirAll(path, 0755)).To(Succeed())
+					continue
+				}
+				Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(path, data, 0644)).To(Succeed())
+			}
+			image, err := extracted.Image("some-ref")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(image.Manifest.Layers).To(Equal([]v1.Descriptor{layerDesc}))
+		})
+	})
+})
+
+func testTar(name, contents string) []byte {
+	buffer := &bytes.Buffer{}
+	tarball := tar.NewWriter(buffer)
+	Expect(tarball.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents))})).To(Succeed())
+	_, err := tarball.Write([]byte(contents))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(tarball.Close()).To(Succeed())
+	return buffer.Bytes()
+}
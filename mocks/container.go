@@ -5,6 +5,7 @@
 package mocks
 
 import (
+	context "context"
 	engine "github.com/buildpack/forge/engine"
 	gomock "github.com/golang/mock/gomock"
 	io "io"
@@ -84,16 +85,43 @@ func (mr *MockContainerMockRecorder) Commit(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Commit", reflect.TypeOf((*MockContainer)(nil).Commit), arg0)
 }
 
-// UploadTarTo mocks base method
-func (m *MockContainer) UploadTarTo(arg0 io.Reader, arg1 string) error {
-	ret := m.ctrl.Call(m, "UploadTarTo", arg0, arg1)
-	ret0, _ := ret[0].(error)
-	return ret0
+// CommitContext mocks base method
+func (m *MockContainer) CommitContext(arg0 context.Context, arg1 string) (string, error) {
+	ret := m.ctrl.Call(m, "CommitContext", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// UploadTarTo indicates an expected call of UploadTarTo
-func (mr *MockContainerMockRecorder) UploadTarTo(arg0, arg1 interface{}) *gomock.Call {
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadTarTo", reflect.TypeOf((*MockContainer)(nil).UploadTarTo), arg0, arg1)
+// CommitContext indicates an expected call of CommitContext
+func (mr *MockContainerMockRecorder) CommitContext(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CommitContext", reflect.TypeOf((*MockContainer)(nil).CommitContext), arg0, arg1)
+}
+
+// Exec mocks base method
+func (m *MockContainer) Exec(arg0 *engine.ExecConfig) (int64, error) {
+	ret := m.ctrl.Call(m, "Exec", arg0)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Exec indicates an expected call of Exec
+func (mr *MockContainerMockRecorder) Exec(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exec", reflect.TypeOf((*MockContainer)(nil).Exec), arg0)
+}
+
+// ExecContext mocks base method
+func (m *MockContainer) ExecContext(arg0 context.Context, arg1 *engine.ExecConfig) (int64, error) {
+	ret := m.ctrl.Call(m, "ExecContext", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExecContext indicates an expected call of ExecContext
+func (mr *MockContainerMockRecorder) ExecContext(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExecContext", reflect.TypeOf((*MockContainer)(nil).ExecContext), arg0, arg1)
 }
 
 // HealthCheck mocks base method
@@ -108,6 +136,18 @@ func (mr *MockContainerMockRecorder) HealthCheck() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HealthCheck", reflect.TypeOf((*MockContainer)(nil).HealthCheck))
 }
 
+// HealthCheckContext mocks base method
+func (m *MockContainer) HealthCheckContext(arg0 context.Context) <-chan string {
+	ret := m.ctrl.Call(m, "HealthCheckContext", arg0)
+	ret0, _ := ret[0].(<-chan string)
+	return ret0
+}
+
+// HealthCheckContext indicates an expected call of HealthCheckContext
+func (mr *MockContainerMockRecorder) HealthCheckContext(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HealthCheckContext", reflect.TypeOf((*MockContainer)(nil).HealthCheckContext), arg0)
+}
+
 // ID mocks base method
 func (m *MockContainer) ID() string {
 	ret := m.ctrl.Call(m, "ID")
@@ -120,6 +160,30 @@ func (mr *MockContainerMockRecorder) ID() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ID", reflect.TypeOf((*MockContainer)(nil).ID))
 }
 
+// Kill mocks base method
+func (m *MockContainer) Kill(arg0 string) error {
+	ret := m.ctrl.Call(m, "Kill", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Kill indicates an expected call of Kill
+func (mr *MockContainerMockRecorder) Kill(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Kill", reflect.TypeOf((*MockContainer)(nil).Kill), arg0)
+}
+
+// KillContext mocks base method
+func (m *MockContainer) KillContext(arg0 context.Context, arg1 string) error {
+	ret := m.ctrl.Call(m, "KillContext", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// KillContext indicates an expected call of KillContext
+func (mr *MockContainerMockRecorder) KillContext(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "KillContext", reflect.TypeOf((*MockContainer)(nil).KillContext), arg0, arg1)
+}
+
 // Mkdir mocks base method
 func (m *MockContainer) Mkdir(arg0 string) error {
 	ret := m.ctrl.Call(m, "Mkdir", arg0)
@@ -132,6 +196,56 @@ func (mr *MockContainerMockRecorder) Mkdir(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Mkdir", reflect.TypeOf((*MockContainer)(nil).Mkdir), arg0)
 }
 
+// Network mocks base method
+func (m *MockContainer) Network() (*engine.NetworkInfo, error) {
+	ret := m.ctrl.Call(m, "Network")
+	ret0, _ := ret[0].(*engine.NetworkInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Network indicates an expected call of Network
+func (mr *MockContainerMockRecorder) Network() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Network", reflect.TypeOf((*MockContainer)(nil).Network))
+}
+
+// NetworkContext mocks base method
+func (m *MockContainer) NetworkContext(arg0 context.Context) (*engine.NetworkInfo, error) {
+	ret := m.ctrl.Call(m, "NetworkContext", arg0)
+	ret0, _ := ret[0].(*engine.NetworkInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NetworkContext indicates an expected call of NetworkContext
+func (mr *MockContainerMockRecorder) NetworkContext(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NetworkContext", reflect.TypeOf((*MockContainer)(nil).NetworkContext), arg0)
+}
+
+// Pause mocks base method
+func (m *MockContainer) Pause() error {
+	ret := m.ctrl.Call(m, "Pause")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Pause indicates an expected call of Pause
+func (mr *MockContainerMockRecorder) Pause() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pause", reflect.TypeOf((*MockContainer)(nil).Pause))
+}
+
+// PauseContext mocks base method
+func (m *MockContainer) PauseContext(arg0 context.Context) error {
+	ret := m.ctrl.Call(m, "PauseContext", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PauseContext indicates an expected call of PauseContext
+func (mr *MockContainerMockRecorder) PauseContext(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PauseContext", reflect.TypeOf((*MockContainer)(nil).PauseContext), arg0)
+}
+
 // Shell mocks base method
 func (m *MockContainer) Shell(arg0 engine.TTY, arg1 ...string) error {
 	varargs := []interface{}{arg0}
@@ -149,6 +263,23 @@ func (mr *MockContainerMockRecorder) Shell(arg0 interface{}, arg1 ...interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Shell", reflect.TypeOf((*MockContainer)(nil).Shell), varargs...)
 }
 
+// ShellContext mocks base method
+func (m *MockContainer) ShellContext(arg0 context.Context, arg1 engine.TTY, arg2 ...string) error {
+	varargs := []interface{}{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ShellContext", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ShellContext indicates an expected call of ShellContext
+func (mr *MockContainerMockRecorder) ShellContext(arg0, arg1 interface{}, arg2 ...interface{}) *gomock.Call {
+	varargs := append([]interface{}{arg0, arg1}, arg2...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShellContext", reflect.TypeOf((*MockContainer)(nil).ShellContext), varargs...)
+}
+
 // Start mocks base method
 func (m *MockContainer) Start(arg0 string, arg1 io.Writer, arg2 <-chan time.Time) (int64, error) {
 	ret := m.ctrl.Call(m, "Start", arg0, arg1, arg2)
@@ -162,6 +293,67 @@ func (mr *MockContainerMockRecorder) Start(arg0, arg1, arg2 interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Start", reflect.TypeOf((*MockContainer)(nil).Start), arg0, arg1, arg2)
 }
 
+// StartContext mocks base method
+func (m *MockContainer) StartContext(arg0 context.Context, arg1 string, arg2 io.Writer, arg3 <-chan time.Time) (int64, error) {
+	ret := m.ctrl.Call(m, "StartContext", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StartContext indicates an expected call of StartContext
+func (mr *MockContainerMockRecorder) StartContext(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartContext", reflect.TypeOf((*MockContainer)(nil).StartContext), arg0, arg1, arg2, arg3)
+}
+
+// Stats mocks base method
+func (m *MockContainer) Stats() <-chan engine.Stats {
+	ret := m.ctrl.Call(m, "Stats")
+	ret0, _ := ret[0].(<-chan engine.Stats)
+	return ret0
+}
+
+// Stats indicates an expected call of Stats
+func (mr *MockContainerMockRecorder) Stats() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockContainer)(nil).Stats))
+}
+
+// StatsContext mocks base method
+func (m *MockContainer) StatsContext(arg0 context.Context) <-chan engine.Stats {
+	ret := m.ctrl.Call(m, "StatsContext", arg0)
+	ret0, _ := ret[0].(<-chan engine.Stats)
+	return ret0
+}
+
+// StatsContext indicates an expected call of StatsContext
+func (mr *MockContainerMockRecorder) StatsContext(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StatsContext", reflect.TypeOf((*MockContainer)(nil).StatsContext), arg0)
+}
+
+// Stop mocks base method
+func (m *MockContainer) Stop(arg0 time.Duration) error {
+	ret := m.ctrl.Call(m, "Stop", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Stop indicates an expected call of Stop
+func (mr *MockContainerMockRecorder) Stop(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stop", reflect.TypeOf((*MockContainer)(nil).Stop), arg0)
+}
+
+// StopContext mocks base method
+func (m *MockContainer) StopContext(arg0 context.Context, arg1 time.Duration) error {
+	ret := m.ctrl.Call(m, "StopContext", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StopContext indicates an expected call of StopContext
+func (mr *MockContainerMockRecorder) StopContext(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StopContext", reflect.TypeOf((*MockContainer)(nil).StopContext), arg0, arg1)
+}
+
 // StreamFileFrom mocks base method
 func (m *MockContainer) StreamFileFrom(arg0 string) (engine.Stream, error) {
 	ret := m.ctrl.Call(m, "StreamFileFrom", arg0)
@@ -175,6 +367,19 @@ func (mr *MockContainerMockRecorder) StreamFileFrom(arg0 interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamFileFrom", reflect.TypeOf((*MockContainer)(nil).StreamFileFrom), arg0)
 }
 
+// StreamFileFromContext mocks base method
+func (m *MockContainer) StreamFileFromContext(arg0 context.Context, arg1 string) (engine.Stream, error) {
+	ret := m.ctrl.Call(m, "StreamFileFromContext", arg0, arg1)
+	ret0, _ := ret[0].(engine.Stream)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StreamFileFromContext indicates an expected call of StreamFileFromContext
+func (mr *MockContainerMockRecorder) StreamFileFromContext(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamFileFromContext", reflect.TypeOf((*MockContainer)(nil).StreamFileFromContext), arg0, arg1)
+}
+
 // StreamFileTo mocks base method
 func (m *MockContainer) StreamFileTo(arg0 engine.Stream, arg1 string) error {
 	ret := m.ctrl.Call(m, "StreamFileTo", arg0, arg1)
@@ -211,3 +416,51 @@ func (m *MockContainer) StreamTarTo(arg0 engine.Stream, arg1 string) error {
 func (mr *MockContainerMockRecorder) StreamTarTo(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamTarTo", reflect.TypeOf((*MockContainer)(nil).StreamTarTo), arg0, arg1)
 }
+
+// Unpause mocks base method
+func (m *MockContainer) Unpause() error {
+	ret := m.ctrl.Call(m, "Unpause")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unpause indicates an expected call of Unpause
+func (mr *MockContainerMockRecorder) Unpause() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unpause", reflect.TypeOf((*MockContainer)(nil).Unpause))
+}
+
+// UnpauseContext mocks base method
+func (m *MockContainer) UnpauseContext(arg0 context.Context) error {
+	ret := m.ctrl.Call(m, "UnpauseContext", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnpauseContext indicates an expected call of UnpauseContext
+func (mr *MockContainerMockRecorder) UnpauseContext(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnpauseContext", reflect.TypeOf((*MockContainer)(nil).UnpauseContext), arg0)
+}
+
+// UploadTarTo mocks base method
+func (m *MockContainer) UploadTarTo(arg0 io.Reader, arg1 string) error {
+	ret := m.ctrl.Call(m, "UploadTarTo", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UploadTarTo indicates an expected call of UploadTarTo
+func (mr *MockContainerMockRecorder) UploadTarTo(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadTarTo", reflect.TypeOf((*MockContainer)(nil).UploadTarTo), arg0, arg1)
+}
+
+// UploadTarToContext mocks base method
+func (m *MockContainer) UploadTarToContext(arg0 context.Context, arg1 io.Reader, arg2 string) error {
+	ret := m.ctrl.Call(m, "UploadTarToContext", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UploadTarToContext indicates an expected call of UploadTarToContext
+func (mr *MockContainerMockRecorder) UploadTarToContext(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadTarToContext", reflect.TypeOf((*MockContainer)(nil).UploadTarToContext), arg0, arg1, arg2)
+}
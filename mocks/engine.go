@@ -1,10 +1,11 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: github.com/buildpack/forge (interfaces: Engine)
+// Source: github.com/buildpack/forge/v2 (interfaces: Engine)
 
 // Package mocks is a generated GoMock package.
 package mocks
 
 import (
+	context "context"
 	engine "github.com/buildpack/forge/engine"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
@@ -33,6 +34,18 @@ func (m *MockEngine) EXPECT() *MockEngineMockRecorder {
 	return m.recorder
 }
 
+// EventsContext mocks base method
+func (m *MockEngine) EventsContext(arg0 context.Context) <-chan engine.Event {
+	ret := m.ctrl.Call(m, "EventsContext", arg0)
+	ret0, _ := ret[0].(<-chan engine.Event)
+	return ret0
+}
+
+// EventsContext indicates an expected call of EventsContext
+func (mr *MockEngineMockRecorder) EventsContext(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EventsContext", reflect.TypeOf((*MockEngine)(nil).EventsContext), arg0)
+}
+
 // NewContainer mocks base method
 func (m *MockEngine) NewContainer(arg0 *engine.ContainerConfig) (engine.Container, error) {
 	ret := m.ctrl.Call(m, "NewContainer", arg0)
@@ -1,7 +1,25 @@
 package v2
 
 import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	godigest "github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
 	"github.com/buildpack/forge/engine"
+	"github.com/buildpack/forge/internal/oci"
 )
 
 type Exporter struct {
@@ -13,30 +31,159 @@ func NewExporter(engine Engine) *Exporter {
 }
 
 type ExportConfig struct {
-	Droplet    engine.Stream
-	Stack      string
-	Ref        string
-	OutputDir  string
-	WorkingDir string
-	AppConfig  *AppConfig
+	Droplet          engine.Stream
+	DropletDigest    string // optional, expected SHA-256 of Droplet
+	Stack            string
+	PullPolicy       PullPolicy             // default: if-not-present
+	PullProgress     chan<- engine.Progress // receives stack image pull progress
+	TransferProgress chan<- engine.Progress // receives droplet transfer progress
+	Ref              string
+	OutputDir        string
+	WorkingDir       string
+	AppConfig        *AppConfig
+	Staging          *StagingMetadata // optional, recorded in the image metadata
+}
+
+type OCIExportConfig struct {
+	Droplet          engine.Stream
+	DropletDigest    string                 // optional, expected SHA-256 of Droplet
+	TransferProgress chan<- engine.Progress // receives droplet transfer progress
+	StackLayout      string                 // OCI image layout containing the stack image
+	Stack            string                 // default: the only image in StackLayout
+	Ref              string
+	Path             string // OCI image layout, created or updated in place
+	Archive          bool   // write Path as an oci-archive tarball instead
+	OutputDir        string
+	WorkingDir       string
+	AppConfig        *AppConfig
+	Staging          *StagingMetadata // optional, recorded in the image metadata
 }
 
-// TODO: use build instead of commit
 func (e *Exporter) Export(config *ExportConfig) (imageID string, err error) {
+	return e.ExportContext(context.Background(), config)
+}
+
+func (e *Exporter) ExportContext(ctx context.Context, config *ExportConfig) (imageID string, err error) {
+	droplet := engine.NewProgressStream(engine.NewDigestStream(config.Droplet, config.DropletDigest), "droplet", config.TransferProgress)
+	defer droplet.Close()
 	containerConfig, err := e.buildConfig(config.AppConfig, config.WorkingDir, config.Stack)
 	if err != nil {
 		return "", err
 	}
-	contr, err := e.engine.NewContainer(containerConfig)
+	image := e.engine.NewImage()
+	if err := pullImage(ctx, image, config.Stack, config.PullPolicy, config.PullProgress); err != nil {
+		return "", err
+	}
+	stack, err := image.InspectContext(ctx, config.Stack)
+	if err != nil {
+		return "", err
+	}
+	uid, gid, err := e.stackOwner(ctx, stack.User, containerConfig)
+	if err != nil {
+		return "", err
+	}
+	layer, err := newDropletLayer(droplet, config.OutputDir, uid, gid)
 	if err != nil {
 		return "", err
 	}
-	defer contr.Close()
+	defer layer.Close()
+	metadata, err := metadataLabel(config.AppConfig, stackMetadata(config.Stack, stack), config.Staging, layer.Checksum)
+	if err != nil {
+		return "", err
+	}
+
+	return loadImage(ctx, image, config.Ref, stack, ocispec.ImageConfig{
+		Env:        mergeEnv(stack.Env, containerConfig.Env),
+		Entrypoint: containerConfig.Entrypoint,
+		Cmd:        containerConfig.Cmd,
+		WorkingDir: containerConfig.WorkingDir,
+		Labels: mergeMaps(stack.Labels, map[string]string{
+			engine.LabelRole:     engine.RoleExport,
+			engine.LabelApp:      config.AppConfig.Name,
+			engine.LabelMetadata: metadata,
+		}),
+	}, layer, "forge export "+config.OutputDir)
+}
+
+// stackOwner returns the IDs of the stack user, reading them from a stack container if the user is named.
+func (e *Exporter) stackOwner(ctx context.Context, user string, config *engine.ContainerConfig) (uid, gid int, err error) {
+	var contr engine.Container
+	defer func() {
+		if contr != nil {
+			contr.Close()
+		}
+	}()
+	return stackOwner(user, func(path string) (io.ReadCloser, error) {
+		if contr == nil {
+			var err error
+			if contr, err = e.engine.NewContainer(config); err != nil {
+				return nil, err
+			}
+		}
+		return contr.StreamFileFromContext(ctx, path)
+	})
+}
 
-	if err := contr.StreamTarTo(config.Droplet, config.OutputDir); err != nil {
+// loadImage loads an image with the droplet layer on top of the stack layers.
+// The image is created at the same time and run by the same user as the stack.
+// Its history is the stack history followed by createdBy, if the stack history matches the stack layers.
+func loadImage(ctx context.Context, image engine.Image, ref string, stack *engine.ImageInfo, config ocispec.ImageConfig, droplet *dropletLayer, createdBy string) (imageID string, err error) {
+	var diffIDs []godigest.Digest
+	for _, diffID := range stack.Layers {
+		diffIDs = append(diffIDs, godigest.Digest(diffID))
+	}
+	config.User = stack.User
+	var history []ocispec.History
+	layers := 0
+	for _, h := range stack.History {
+		created := h.Created
+		history = append(history, ocispec.History{
+			Created:    &created,
+			CreatedBy:  h.CreatedBy,
+			Comment:    h.Comment,
+			EmptyLayer: h.EmptyLayer,
+		})
+		if !h.EmptyLayer {
+			layers++
+		}
+	}
+	if layers == len(stack.Layers) {
+		history = append(history, ocispec.History{Created: &stack.Created, CreatedBy: createdBy})
+	} else {
+		history = nil
+	}
+	imageConfig, err := json.Marshal(ocispec.Image{
+		Created:      &stack.Created,
+		OS:           stack.OS,
+		Architecture: stack.Architecture,
+		Config:       config,
+		RootFS:       ocispec.RootFS{Type: "layers", DiffIDs: append(diffIDs, droplet.DiffID)},
+		History:      history,
+	})
+	if err != nil {
 		return "", err
 	}
-	return contr.Commit(config.Ref)
+	archive, err := imageArchive(ref, imageConfig, diffIDs, droplet)
+	if err != nil {
+		return "", err
+	}
+	defer archive.Close()
+	var loadErr error
+	for progress := range image.LoadContext(ctx, archive) {
+		if _, err := progress.Status(); err != nil && loadErr == nil {
+			loadErr = err
+		}
+		if progress.Aux != nil && progress.Aux.ImageID != "" {
+			imageID = progress.Aux.ImageID
+		}
+	}
+	if loadErr != nil {
+		return "", loadErr
+	}
+	if imageID == "" {
+		return "", errors.New("no image ID received when loading exported image")
+	}
+	return imageID, nil
 }
 
 func (e *Exporter) buildConfig(app *AppConfig, workingDir, stack string) (*engine.ContainerConfig, error) {
@@ -44,11 +191,16 @@ func (e *Exporter) buildConfig(app *AppConfig, workingDir, stack string) (*engin
 	if app.Name != "" {
 		env["PACK_APP_NAME"] = app.Name
 	}
+	// sorted so that the same app config always produces the same image
+	appEnv := mapToEnv(mergeMaps(env, app.RunningEnv, app.Env))
+	sort.Strings(appEnv)
 
 	return &engine.ContainerConfig{
 		Name:       app.Name,
+		AppName:    app.Name,
+		Role:       engine.RoleExport,
 		Hostname:   app.Name,
-		Env:        mapToEnv(mergeMaps(env, app.RunningEnv, app.Env)),
+		Env:        appEnv,
 		Image:      stack,
 		WorkingDir: workingDir,
 		Entrypoint: []string{"/packs/launcher"},
@@ -56,3 +208,254 @@ func (e *Exporter) buildConfig(app *AppConfig, workingDir, stack string) (*engin
 		SkipProxy:  true,
 	}, nil
 }
+
+func (e *Exporter) ExportOCI(config *OCIExportConfig) (digest string, err error) {
+	return e.ExportOCIContext(context.Background(), config)
+}
+
+func (e *Exporter) ExportOCIContext(ctx context.Context, config *OCIExportConfig) (digest string, err error) {
+	dropletStream := engine.NewProgressStream(engine.NewDigestStream(config.Droplet, config.DropletDigest), "droplet", config.TransferProgress)
+	defer dropletStream.Close()
+	containerConfig, err := e.buildConfig(config.AppConfig, config.WorkingDir, config.Stack)
+	if err != nil {
+		return "", err
+	}
+	stackLayout := oci.Layout(config.StackLayout)
+	stack, err := stackLayout.Image(config.Stack)
+	if err != nil {
+		return "", err
+	}
+
+	layout := oci.Layout(config.Path)
+	if config.Archive {
+		tmpDir, err := ioutil.TempDir("", "forge-oci")
+		if err != nil {
+			return "", err
+		}
+		defer os.RemoveAll(tmpDir)
+		layout = oci.Layout(tmpDir)
+	}
+	for _, layer := range stack.Manifest.Layers {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if err := layout.CopyBlob(stackLayout, layer); err != nil {
+			return "", err
+		}
+	}
+	uid, gid, err := stackOwner(stack.Config.Config.User, func(path string) (io.ReadCloser, error) {
+		return layerFile(stackLayout, stack.Manifest.Layers, path)
+	})
+	if err != nil {
+		return "", err
+	}
+	droplet, err := newDropletLayer(dropletStream, config.OutputDir, uid, gid)
+	if err != nil {
+		return "", err
+	}
+	defer droplet.Close()
+	dropletLayer, diffID, err := layout.WriteLayer(droplet.Reader())
+	if err != nil {
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	stackMetadata := StackMetadata{Ref: config.Stack, ID: stack.Manifest.Config.Digest.String()}
+	metadata, err := metadataLabel(config.AppConfig, stackMetadata, config.Staging, droplet.Checksum)
+	if err != nil {
+		return "", err
+	}
+
+	image := stack.Config
+	image.Config.Env = mergeEnv(image.Config.Env, containerConfig.Env)
+	image.Config.Entrypoint = containerConfig.Entrypoint
+	image.Config.Cmd = containerConfig.Cmd
+	image.Config.WorkingDir = containerConfig.WorkingDir
+	image.Config.Labels = mergeMaps(image.Config.Labels, map[string]string{
+		engine.LabelRole:     engine.RoleExport,
+		engine.LabelApp:      config.AppConfig.Name,
+		engine.LabelMetadata: metadata,
+	})
+	image.RootFS.DiffIDs = append(append([]godigest.Digest(nil), image.RootFS.DiffIDs...), diffID)
+	image.History = append(append([]ocispec.History(nil), image.History...), ocispec.History{
+		Created:   image.Created,
+		CreatedBy: "forge export " + config.OutputDir,
+	})
+	imageConfig, err := layout.WriteJSON(ocispec.MediaTypeImageConfig, image)
+	if err != nil {
+		return "", err
+	}
+	manifest, err := layout.WriteJSON(ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		Config:    imageConfig,
+		Layers:    append(append([]ocispec.Descriptor(nil), stack.Manifest.Layers...), dropletLayer),
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := layout.Tag(manifest, config.Ref); err != nil {
+		return "", err
+	}
+	if config.Archive {
+		if err := writeArchive(layout, config.Path); err != nil {
+			return "", err
+		}
+	}
+	return manifest.Digest.String(), nil
+}
+
+// layerFile reads a file from the top-most of the layers that contains it.
+func layerFile(layout oci.Layout, layers []ocispec.Descriptor, name string) (io.ReadCloser, error) {
+	name = strings.TrimPrefix(path.Clean(name), "/")
+	whiteout := path.Join(path.Dir(name), ".wh."+path.Base(name))
+	for i := len(layers) - 1; i >= 0; i-- {
+		contents, found, err := readLayerFile(layout, layers[i], name, whiteout)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+		if contents == nil {
+			break
+		}
+		return ioutil.NopCloser(bytes.NewReader(contents)), nil
+	}
+	return nil, fmt.Errorf("%s not found in stack", name)
+}
+
+// readLayerFile reads a file from a layer, returning nil contents if the layer removes it.
+func readLayerFile(layout oci.Layout, layer ocispec.Descriptor, name, whiteout string) (contents []byte, found bool, err error) {
+	blob, err := layout.Open(layer)
+	if err != nil {
+		return nil, false, err
+	}
+	defer blob.Close()
+	in, err := uncompressed(blob)
+	if err != nil {
+		return nil, false, err
+	}
+	tarball := tar.NewReader(in)
+	for {
+		header, err := tarball.Next()
+		if err == io.EOF {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		switch strings.TrimPrefix(path.Clean(header.Name), "/") {
+		case whiteout:
+			return nil, true, nil
+		case name:
+			if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+				return nil, false, fmt.Errorf("%s is not a regular file in stack", name)
+			}
+			contents, err := ioutil.ReadAll(tarball)
+			return contents, true, err
+		}
+	}
+}
+
+func writeArchive(layout oci.Layout, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := layout.Archive(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// imageArchive returns a docker save archive of the image with the droplet layer on top of the stack layers.
+// Stack layers are referenced by diff ID but left out, as the engine already has them.
+func imageArchive(ref string, config []byte, stackLayers []godigest.Digest, droplet *dropletLayer) (engine.Stream, error) {
+	configName := godigest.FromBytes(config).Hex() + ".json"
+	dropletName := droplet.DiffID.Hex() + "/layer.tar"
+	var layerNames []string
+	for _, diffID := range stackLayers {
+		layerNames = append(layerNames, diffID.Hex()+"/layer.tar")
+	}
+	var repoTags []string
+	if ref != "" {
+		repoTags = []string{tagRef(ref)}
+	}
+	manifest, err := json.Marshal([]struct {
+		Config   string
+		RepoTags []string
+		Layers   []string
+	}{{configName, repoTags, append(layerNames, dropletName)}})
+	if err != nil {
+		return engine.Stream{}, err
+	}
+
+	files := []archiveFile{
+		{configName, bytes.NewReader(config), int64(len(config))},
+		{dropletName, droplet.Reader(), droplet.Size},
+		{"manifest.json", bytes.NewReader(manifest), int64(len(manifest))},
+	}
+	size := int64(2 * 512)
+	for _, file := range files {
+		size += 512 + (file.size+511)/512*512
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeArchiveFiles(pw, files))
+	}()
+	return engine.NewStream(pr, size), nil
+}
+
+type archiveFile struct {
+	name string
+	data io.Reader
+	size int64
+}
+
+func writeArchiveFiles(w io.Writer, files []archiveFile) error {
+	tarball := tar.NewWriter(w)
+	for _, file := range files {
+		if err := tarball.WriteHeader(&tar.Header{
+			Name:     file.name,
+			Mode:     0644,
+			Size:     file.size,
+			Typeflag: tar.TypeReg,
+			ModTime:  dropletModTime,
+		}); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tarball, file.data); err != nil {
+			return err
+		}
+	}
+	return tarball.Close()
+}
+
+// tagRef adds the latest tag to untagged refs.
+func tagRef(ref string) string {
+	if strings.Contains(ref, "@") || strings.LastIndex(ref, ":") > strings.LastIndex(ref, "/") {
+		return ref
+	}
+	return ref + ":latest"
+}
+
+func mergeEnv(envs ...[]string) []string {
+	var keys []string
+	merged := map[string]string{}
+	for _, env := range envs {
+		for _, kv := range env {
+			k := strings.SplitN(kv, "=", 2)[0]
+			if _, ok := merged[k]; !ok {
+				keys = append(keys, k)
+			}
+			merged[k] = kv
+		}
+	}
+	var out []string
+	for _, k := range keys {
+		out = append(out, merged[k])
+	}
+	return out
+}
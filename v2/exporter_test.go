@@ -1,29 +1,47 @@
 package v2_test
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 
 	"github.com/buildpack/forge/engine"
+	"github.com/buildpack/forge/internal/oci"
 	"github.com/buildpack/forge/mocks"
 	. "github.com/buildpack/forge/v2"
 )
 
 var _ = Describe("Exporter", func() {
 	var (
-		exporter      *Exporter
-		mockCtrl      *gomock.Controller
-		mockEngine    *mocks.MockEngine
-		mockContainer *mocks.MockContainer
+		exporter   *Exporter
+		mockCtrl   *gomock.Controller
+		mockEngine *mocks.MockEngine
+		mockImage  *mocks.MockImage
 	)
 
 	BeforeEach(func() {
 		mockCtrl = gomock.NewController(GinkgoT())
 		mockEngine = mocks.NewMockEngine(mockCtrl)
-		mockContainer = mocks.NewMockContainer(mockCtrl)
+		mockImage = mocks.NewMockImage(mockCtrl)
+
+		mockEngine.EXPECT().NewImage().Return(mockImage).AnyTimes()
 
 		exporter = NewExporter(mockEngine)
 	})
@@ -33,13 +51,28 @@ var _ = Describe("Exporter", func() {
 	})
 
 	Describe("#Export", func() {
-		It("should load the provided droplet into a Docker image with the lifecycle", func() {
-			config := &ExportConfig{
-				Droplet:    engine.NewStream(mockReadCloser{Value: "some-droplet"}, 100),
+		var (
+			config    *ExportConfig
+			stackInfo *engine.ImageInfo
+		)
+
+		droplet := testTar(true, map[string]string{"app/some-file": "some-app-data"})
+		dropletChecksum := digest.FromBytes(droplet).String()
+		newDroplet := func() engine.Stream {
+			return engine.NewStream(ioutil.NopCloser(bytes.NewReader(droplet)), int64(len(droplet)))
+		}
+
+		BeforeEach(func() {
+			config = &ExportConfig{
+				Droplet:    newDroplet(),
 				Stack:      "some-stack",
 				Ref:        "some-ref",
 				OutputDir:  "/home/vcap",
 				WorkingDir: "/home/vcap/app",
+				Staging: &StagingMetadata{
+					Buildpacks:   []BuildpackMetadata{{Key: "some-checksum", Name: "some-buildpack", Version: "1.2.3"}},
+					StartCommand: "some-staged-command",
+				},
 				AppConfig: &AppConfig{
 					Name:      "some-name",
 					Command:   "some-command",
@@ -62,29 +95,525 @@ var _ = Describe("Exporter", func() {
 					},
 				},
 			}
-			mockEngine.EXPECT().NewContainer(gomock.Any()).Do(func(config *engine.ContainerConfig) {
-				Expect(config.Name).To(Equal("some-name"))
-				Expect(config.Hostname).To(Equal("some-name"))
-				sort.Strings(config.Env)
-				Expect(config.Env).To(Equal([]string{
-					"PACK_APP_NAME=some-name",
-					"TEST_ENV_KEY=test-env-value",
-					"TEST_RUNNING_ENV_KEY=test-running-env-value",
-				}))
-				Expect(config.Image).To(Equal("some-stack"))
-				Expect(config.WorkingDir).To(Equal("/home/vcap/app"))
-				Expect(config.Entrypoint).To(Equal([]string{"/packs/launcher"}))
-				Expect(config.Cmd).To(Equal([]string{"some-command"}))
-			}).Return(mockContainer, nil)
+			stackInfo = &engine.ImageInfo{
+				ID:           "some-stack-id",
+				RepoDigests:  []string{"some-stack@sha256:some-digest"},
+				Labels:       map[string]string{"some-label": "some-value"},
+				Env:          []string{"PATH=/usr/bin", "TEST_ENV_KEY=some-stack-value"},
+				User:         "2000:2000",
+				OS:           "linux",
+				Architecture: "amd64",
+				Created:      time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC),
+				Layers:       []string{"sha256:" + strings.Repeat("1", 64)},
+				History: []engine.ImageHistory{
+					{Created: time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC), CreatedBy: "some-stack-command"},
+					{Created: time.Date(2017, time.January, 2, 0, 0, 0, 0, time.UTC), CreatedBy: "some-stack-env", EmptyLayer: true},
+				},
+			}
+		})
+
+		It("should load an image with the droplet layer on top of the stack layers", func() {
+			var files map[string]string
+			gomock.InOrder(
+				mockImage.EXPECT().ExistsContext(gomock.Any(), "some-stack").Return(true, nil),
+				mockImage.EXPECT().InspectContext(gomock.Any(), "some-stack").Return(stackInfo, nil),
+				mockImage.EXPECT().LoadContext(gomock.Any(), gomock.Any()).Do(func(_ context.Context, archive engine.Stream) {
+					var err error
+					files, err = readTestTar(archive, false)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(archive.Size).To(Equal(int64(archiveSize(files))))
+				}).Return(loadedImage("some-image-id")),
+			)
+			Expect(exporter.Export(config)).To(Equal("some-image-id"))
+
+			var manifest []struct {
+				Config   string
+				RepoTags []string
+				Layers   []string
+			}
+			Expect(json.Unmarshal([]byte(files["manifest.json"]), &manifest)).To(Succeed())
+			Expect(manifest).To(HaveLen(1))
+			Expect(manifest[0].RepoTags).To(Equal([]string{"some-ref:latest"}))
+			Expect(manifest[0].Layers).To(HaveLen(2))
+			Expect(manifest[0].Layers[0]).To(Equal(strings.Repeat("1", 64) + "/layer.tar"))
+			Expect(files).NotTo(HaveKey(manifest[0].Layers[0]))
+			Expect(files).To(HaveLen(3))
+
+			var image ocispec.Image
+			Expect(json.Unmarshal([]byte(files[manifest[0].Config]), &image)).To(Succeed())
+			Expect(manifest[0].Config).To(Equal(digest.FromString(files[manifest[0].Config]).Hex() + ".json"))
+			Expect(*image.Created).To(Equal(stackInfo.Created))
+			Expect(image.OS).To(Equal("linux"))
+			Expect(image.Architecture).To(Equal("amd64"))
+			Expect(image.Config.User).To(Equal("2000:2000"))
+			Expect(image.Config.Env).To(ConsistOf(
+				"PATH=/usr/bin",
+				"TEST_ENV_KEY=test-env-value",
+				"TEST_RUNNING_ENV_KEY=test-running-env-value",
+				"PACK_APP_NAME=some-name",
+			))
+			Expect(image.Config.Entrypoint).To(Equal([]string{"/packs/launcher"}))
+			Expect(image.Config.Cmd).To(Equal([]string{"some-command"}))
+			Expect(image.Config.WorkingDir).To(Equal("/home/vcap/app"))
+			Expect(image.Config.Labels).To(HaveLen(4))
+			Expect(image.Config.Labels).To(HaveKeyWithValue("some-label", "some-value"))
+			Expect(image.Config.Labels).To(HaveKeyWithValue(engine.LabelRole, engine.RoleExport))
+			Expect(image.Config.Labels).To(HaveKeyWithValue(engine.LabelApp, "some-name"))
+			Expect(image.Config.Labels[engine.LabelMetadata]).To(MatchJSON(`{
+				"app": "some-name",
+				"stack": {"ref": "some-stack", "id": "some-stack-id", "digest": "some-stack@sha256:some-digest"},
+				"buildpacks": [{"key": "some-checksum", "name": "some-buildpack", "version": "1.2.3"}],
+				"droplet": {"checksum": "` + dropletChecksum + `"},
+				"start_command": "some-command",
+				"forge_version": "dev"
+			}`))
+			Expect(image.RootFS.DiffIDs).To(HaveLen(2))
+			Expect(image.RootFS.DiffIDs[0]).To(Equal(digest.Digest(stackInfo.Layers[0])))
+			Expect(image.History).To(HaveLen(3))
+			Expect(*image.History[0].Created).To(Equal(stackInfo.History[0].Created))
+			Expect(image.History[0].CreatedBy).To(Equal("some-stack-command"))
+			Expect(image.History[1].CreatedBy).To(Equal("some-stack-env"))
+			Expect(image.History[1].EmptyLayer).To(BeTrue())
+			Expect(*image.History[2].Created).To(Equal(stackInfo.Created))
+			Expect(image.History[2].CreatedBy).To(Equal("forge export /home/vcap"))
+			Expect(image.History[2].EmptyLayer).To(BeFalse())
+
+			layer := files[manifest[0].Layers[1]]
+			Expect(digest.FromString(layer)).To(Equal(image.RootFS.DiffIDs[1]))
+			Expect(readTestTar(strings.NewReader(layer), false)).To(Equal(map[string]string{
+				"home/":                   "",
+				"home/vcap/":              "",
+				"home/vcap/app/":          "",
+				"home/vcap/app/some-file": "some-app-data",
+			}))
+			tarball := tar.NewReader(strings.NewReader(layer))
+			for {
+				header, err := tarball.Next()
+				if err == io.EOF {
+					break
+				}
+				Expect(err).NotTo(HaveOccurred())
+				Expect(header.ModTime).To(Equal(time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC)))
+				if header.Name == "home/" {
+					Expect(header.Uid).To(Equal(0))
+				} else {
+					Expect(header.Uid).To(Equal(2000))
+					Expect(header.Gid).To(Equal(2000))
+				}
+			}
+		})
+
+		It("should own the droplet by a stack user given by name", func() {
+			mockContainer := mocks.NewMockContainer(mockCtrl)
+			passwd := "root:x:0:0:root:/root:/bin/bash\nvcap:x:2001:2002::/home/vcap:/bin/bash\n"
+			stackInfo.User = "vcap"
+			var files map[string]string
+			gomock.InOrder(
+				mockImage.EXPECT().ExistsContext(gomock.Any(), "some-stack").Return(true, nil),
+				mockImage.EXPECT().InspectContext(gomock.Any(), "some-stack").Return(stackInfo, nil),
+				mockEngine.EXPECT().NewContainer(gomock.Any()).Do(func(config *engine.ContainerConfig) {
+					Expect(config.Image).To(Equal("some-stack"))
+				}).Return(mockContainer, nil),
+				mockContainer.EXPECT().StreamFileFromContext(gomock.Any(), "/etc/passwd").
+					Return(engine.NewStream(ioutil.NopCloser(strings.NewReader(passwd)), int64(len(passwd))), nil),
+				mockContainer.EXPECT().Close(),
+				mockImage.EXPECT().LoadContext(gomock.Any(), gomock.Any()).Do(func(_ context.Context, archive engine.Stream) {
+					var err error
+					files, err = readTestTar(archive, false)
+					Expect(err).NotTo(HaveOccurred())
+				}).Return(loadedImage("some-image-id")),
+			)
+			Expect(exporter.Export(config)).To(Equal("some-image-id"))
+
+			var manifest []struct{ Layers []string }
+			Expect(json.Unmarshal([]byte(files["manifest.json"]), &manifest)).To(Succeed())
+			tarball := tar.NewReader(strings.NewReader(files[manifest[0].Layers[1]]))
+			for {
+				header, err := tarball.Next()
+				if err == io.EOF {
+					break
+				}
+				Expect(err).NotTo(HaveOccurred())
+				if header.Name != "home/" {
+					Expect(header.Uid).To(Equal(2001))
+					Expect(header.Gid).To(Equal(2002))
+				}
+			}
+		})
+
+		It("should return an error when the stack user is not found", func() {
+			mockContainer := mocks.NewMockContainer(mockCtrl)
+			passwd := "root:x:0:0:root:/root:/bin/bash\n"
+			stackInfo.User = "vcap"
 			gomock.InOrder(
-				mockContainer.EXPECT().StreamTarTo(config.Droplet, "/home/vcap"),
-				mockContainer.EXPECT().Commit("some-ref").Return("some-image-id", nil),
+				mockImage.EXPECT().ExistsContext(gomock.Any(), "some-stack").Return(true, nil),
+				mockImage.EXPECT().InspectContext(gomock.Any(), "some-stack").Return(stackInfo, nil),
+				mockEngine.EXPECT().NewContainer(gomock.Any()).Return(mockContainer, nil),
+				mockContainer.EXPECT().StreamFileFromContext(gomock.Any(), "/etc/passwd").
+					Return(engine.NewStream(ioutil.NopCloser(strings.NewReader(passwd)), int64(len(passwd))), nil),
 				mockContainer.EXPECT().Close(),
 			)
+			_, err := exporter.Export(config)
+			Expect(err).To(MatchError("user vcap not found in stack"))
+		})
+
+		It("should leave out the history when it does not match the stack layers", func() {
+			stackInfo.History = stackInfo.History[1:]
+			var files map[string]string
+			gomock.InOrder(
+				mockImage.EXPECT().ExistsContext(gomock.Any(), "some-stack").Return(true, nil),
+				mockImage.EXPECT().InspectContext(gomock.Any(), "some-stack").Return(stackInfo, nil),
+				mockImage.EXPECT().LoadContext(gomock.Any(), gomock.Any()).Do(func(_ context.Context, archive engine.Stream) {
+					var err error
+					files, err = readTestTar(archive, false)
+					Expect(err).NotTo(HaveOccurred())
+				}).Return(loadedImage("some-image-id")),
+			)
 			Expect(exporter.Export(config)).To(Equal("some-image-id"))
+
+			var manifest []struct{ Config string }
+			Expect(json.Unmarshal([]byte(files["manifest.json"]), &manifest)).To(Succeed())
+			var image ocispec.Image
+			Expect(json.Unmarshal([]byte(files[manifest[0].Config]), &image)).To(Succeed())
+			Expect(image.History).To(BeEmpty())
+		})
+
+		It("should produce the same image when the same droplet is exported twice", func() {
+			var configs []string
+			mockImage.EXPECT().ExistsContext(gomock.Any(), "some-stack").Return(true, nil).Times(2)
+			mockImage.EXPECT().InspectContext(gomock.Any(), "some-stack").Return(stackInfo, nil).Times(2)
+			mockImage.EXPECT().LoadContext(gomock.Any(), gomock.Any()).Do(func(_ context.Context, archive engine.Stream) {
+				files, err := readTestTar(archive, false)
+				Expect(err).NotTo(HaveOccurred())
+				var manifest []struct{ Config string }
+				Expect(json.Unmarshal([]byte(files["manifest.json"]), &manifest)).To(Succeed())
+				configs = append(configs, manifest[0].Config)
+			}).Return(loadedImage("some-image-id")).Times(2)
+
+			_, err := exporter.Export(config)
+			Expect(err).NotTo(HaveOccurred())
+			config.Droplet = newDroplet()
+			_, err = exporter.Export(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configs).To(HaveLen(2))
+			Expect(configs[0]).To(Equal(configs[1]))
+		})
+
+		It("should return an error when the droplet does not match its digest", func() {
+			config.DropletDigest = "sha256:" + strings.Repeat("0", 64)
+			gomock.InOrder(
+				mockImage.EXPECT().ExistsContext(gomock.Any(), "some-stack").Return(true, nil),
+				mockImage.EXPECT().InspectContext(gomock.Any(), "some-stack").Return(stackInfo, nil),
+			)
+			_, err := exporter.Export(config)
+			Expect(err).To(MatchError(&engine.DigestError{
+				Expected: "sha256:" + strings.Repeat("0", 64),
+				Actual:   dropletChecksum,
+			}))
+		})
+
+		It("should return an error when the image fails to load", func() {
+			progress := make(chan engine.Progress, 1)
+			progress <- engine.Progress{Err: errors.New("some-error")}
+			close(progress)
+			gomock.InOrder(
+				mockImage.EXPECT().ExistsContext(gomock.Any(), "some-stack").Return(true, nil),
+				mockImage.EXPECT().InspectContext(gomock.Any(), "some-stack").Return(stackInfo, nil),
+				mockImage.EXPECT().LoadContext(gomock.Any(), gomock.Any()).Return(progress),
+			)
+			_, err := exporter.Export(config)
+			Expect(err).To(MatchError("some-error"))
+		})
+
+		It("should return an error when the stack image cannot be inspected", func() {
+			gomock.InOrder(
+				mockImage.EXPECT().ExistsContext(gomock.Any(), "some-stack").Return(true, nil),
+				mockImage.EXPECT().InspectContext(gomock.Any(), "some-stack").Return(nil, errors.New("some-error")),
+			)
+			_, err := exporter.Export(config)
+			Expect(err).To(MatchError("some-error"))
+		})
+
+		Context("when the stack image is missing", func() {
+			It("should pull the stack image and report progress", func() {
+				progress := make(chan engine.Progress, 2)
+				config.PullProgress = progress
+				pull := make(chan engine.Progress, 2)
+				pull <- engine.Progress{ID: "some-layer", Bar: "some-bar"}
+				pull <- engine.Progress{ID: "some-other-layer", Bar: "some-other-bar"}
+				close(pull)
+
+				gomock.InOrder(
+					mockImage.EXPECT().ExistsContext(gomock.Any(), "some-stack").Return(false, nil),
+					mockImage.EXPECT().PullContext(gomock.Any(), "some-stack").Return(pull),
+					mockImage.EXPECT().InspectContext(gomock.Any(), "some-stack").Return(stackInfo, nil),
+					mockImage.EXPECT().LoadContext(gomock.Any(), gomock.Any()).Return(loadedImage("some-image-id")),
+				)
+				Expect(exporter.Export(config)).To(Equal("some-image-id"))
+				Expect(progress).To(Receive(Equal(engine.Progress{ID: "some-layer", Bar: "some-bar"})))
+				Expect(progress).To(Receive(Equal(engine.Progress{ID: "some-other-layer", Bar: "some-other-bar"})))
+			})
+
+			It("should return an error when the pull fails", func() {
+				pull := make(chan engine.Progress, 1)
+				pull <- engine.Progress{Err: errors.New("some-error")}
+				close(pull)
+
+				gomock.InOrder(
+					mockImage.EXPECT().ExistsContext(gomock.Any(), "some-stack").Return(false, nil),
+					mockImage.EXPECT().PullContext(gomock.Any(), "some-stack").Return(pull),
+				)
+				_, err := exporter.Export(config)
+				Expect(err).To(MatchError("some-error"))
+			})
+
+			It("should return an ImageNotFoundError when the pull policy is never", func() {
+				config.PullPolicy = PullNever
+				mockImage.EXPECT().ExistsContext(gomock.Any(), "some-stack").Return(false, nil)
+				_, err := exporter.Export(config)
+				Expect(err).To(Equal(&ImageNotFoundError{Ref: "some-stack"}))
+				Expect(err).To(MatchError("image some-stack not found locally and pull policy is never"))
+			})
+		})
+
+		It("should always pull the stack image when the pull policy is always", func() {
+			config.PullPolicy = PullAlways
+			pull := make(chan engine.Progress)
+			close(pull)
+
+			gomock.InOrder(
+				mockImage.EXPECT().PullContext(gomock.Any(), "some-stack").Return(pull),
+				mockImage.EXPECT().InspectContext(gomock.Any(), "some-stack").Return(stackInfo, nil),
+				mockImage.EXPECT().LoadContext(gomock.Any(), gomock.Any()).Return(loadedImage("some-image-id")),
+			)
+			Expect(exporter.Export(config)).To(Equal("some-image-id"))
+		})
+
+		It("should return an error when the pull policy is invalid", func() {
+			config.PullPolicy = "some-policy"
+			_, err := exporter.Export(config)
+			Expect(err).To(MatchError("invalid pull policy: some-policy"))
 		})
 
 		// TODO: test with custom start command
 		// TODO: test with empty app dir / without rsync
 	})
+
+	Describe("#ExportOCI", func() {
+		var (
+			tmpDir            string
+			config            *OCIExportConfig
+			droplet           []byte
+			stackConfigDigest string
+		)
+
+		BeforeEach(func() {
+			var err error
+			tmpDir, err = ioutil.TempDir("", "forge.exporter.test")
+			Expect(err).NotTo(HaveOccurred())
+
+			stack := oci.Layout(filepath.Join(tmpDir, "stack"))
+			stackLayer, diffID, err := stack.WriteLayer(bytes.NewReader(testTar(false, map[string]string{
+				"etc/some-stack-file": "some-stack-data",
+				"etc/passwd":          "root:x:0:0:root:/root:/bin/bash\nvcap:x:2000:2000::/home/vcap:/bin/bash\n",
+			})))
+			Expect(err).NotTo(HaveOccurred())
+			stackConfig, err := stack.WriteJSON(ocispec.MediaTypeImageConfig, ocispec.Image{
+				OS:           "linux",
+				Architecture: "amd64",
+				Config: ocispec.ImageConfig{
+					User:   "vcap",
+					Env:    []string{"PATH=/usr/bin", "TEST_ENV_KEY=some-stack-value"},
+					Labels: map[string]string{"some-label": "some-value"},
+				},
+				RootFS:  ocispec.RootFS{Type: "layers", DiffIDs: []digest.Digest{diffID}},
+				History: []ocispec.History{{CreatedBy: "some-stack-command"}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			stackManifest, err := stack.WriteJSON(ocispec.MediaTypeImageManifest, ocispec.Manifest{
+				Versioned: specs.Versioned{SchemaVersion: 2},
+				Config:    stackConfig,
+				Layers:    []ocispec.Descriptor{stackLayer},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stack.Tag(stackManifest, "some-stack")).To(Succeed())
+			stackConfigDigest = stackConfig.Digest.String()
+
+			droplet = testTar(true, map[string]string{"app/some-file": "some-app-data"})
+			config = &OCIExportConfig{
+				Droplet:     engine.NewStream(ioutil.NopCloser(bytes.NewReader(droplet)), int64(len(droplet))),
+				StackLayout: filepath.Join(tmpDir, "stack"),
+				Stack:       "some-stack",
+				Ref:         "some-ref",
+				Path:        filepath.Join(tmpDir, "image"),
+				OutputDir:   "/home/vcap",
+				WorkingDir:  "/home/vcap/app",
+				AppConfig: &AppConfig{
+					Name:    "some-name",
+					Command: "some-command",
+					Env:     map[string]string{"TEST_ENV_KEY": "test-env-value"},
+				},
+			}
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(tmpDir)).To(Succeed())
+		})
+
+		It("should write an OCI image layout with the droplet on top of the stack layers", func() {
+			manifestDigest, err := exporter.ExportOCI(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manifestDigest).To(HavePrefix("sha256:"))
+
+			layout := oci.Layout(config.Path)
+			image, err := layout.Image("some-ref")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(image.Config.OS).To(Equal("linux"))
+			Expect(image.Config.Config.Env).To(ConsistOf(
+				"PATH=/usr/bin",
+				"TEST_ENV_KEY=test-env-value",
+				"PACK_APP_NAME=some-name",
+			))
+			Expect(image.Config.Config.Entrypoint).To(Equal([]string{"/packs/launcher"}))
+			Expect(image.Config.Config.Cmd).To(Equal([]string{"some-command"}))
+			Expect(image.Config.Config.WorkingDir).To(Equal("/home/vcap/app"))
+			Expect(image.Config.Config.Labels).To(HaveLen(4))
+			Expect(image.Config.Config.Labels).To(HaveKeyWithValue("some-label", "some-value"))
+			Expect(image.Config.Config.Labels).To(HaveKeyWithValue(engine.LabelRole, engine.RoleExport))
+			Expect(image.Config.Config.Labels).To(HaveKeyWithValue(engine.LabelApp, "some-name"))
+			var metadata ImageMetadata
+			Expect(json.Unmarshal([]byte(image.Config.Config.Labels[engine.LabelMetadata]), &metadata)).To(Succeed())
+			Expect(metadata).To(Equal(ImageMetadata{
+				App:          "some-name",
+				Stack:        StackMetadata{Ref: "some-stack", ID: stackConfigDigest},
+				Buildpacks:   []BuildpackMetadata{},
+				Droplet:      DropletMetadata{Checksum: digest.FromBytes(droplet).String()},
+				StartCommand: "some-command",
+				ForgeVersion: "dev",
+			}))
+			Expect(image.Config.RootFS.DiffIDs).To(HaveLen(2))
+			Expect(image.Config.History).To(HaveLen(2))
+			Expect(image.Config.History[1].CreatedBy).To(Equal("forge export /home/vcap"))
+			Expect(image.Manifest.Layers).To(HaveLen(2))
+
+			layer, err := layout.Open(image.Manifest.Layers[1])
+			Expect(err).NotTo(HaveOccurred())
+			defer layer.Close()
+			Expect(readTestTar(layer, true)).To(Equal(map[string]string{
+				"home/":                   "",
+				"home/vcap/":              "",
+				"home/vcap/app/":          "",
+				"home/vcap/app/some-file": "some-app-data",
+			}))
+
+			layer, err = layout.Open(image.Manifest.Layers[1])
+			Expect(err).NotTo(HaveOccurred())
+			defer layer.Close()
+			gz, err := gzip.NewReader(layer)
+			Expect(err).NotTo(HaveOccurred())
+			tarball := tar.NewReader(gz)
+			for {
+				header, err := tarball.Next()
+				if err == io.EOF {
+					break
+				}
+				Expect(err).NotTo(HaveOccurred())
+				if header.Name != "home/" {
+					Expect(header.Uid).To(Equal(2000))
+					Expect(header.Gid).To(Equal(2000))
+				}
+			}
+		})
+
+		It("should write an oci-archive tarball", func() {
+			config.Archive = true
+			_, err := exporter.ExportOCI(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			archive, err := os.Open(config.Path)
+			Expect(err).NotTo(HaveOccurred())
+			defer archive.Close()
+			files, err := readTestTar(archive, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files).To(HaveKey("oci-layout"))
+			Expect(files).To(HaveKey("index.json"))
+			Expect(files).To(HaveKey("blobs/sha256/"))
+		})
+
+		It("should return an error when the stack image is missing", func() {
+			config.Stack = "some-missing-stack"
+			_, err := exporter.ExportOCI(config)
+			Expect(err).To(MatchError(HavePrefix("image some-missing-stack not found")))
+		})
+	})
 })
+
+func testTar(gzipped bool, files map[string]string) []byte {
+	buffer := &bytes.Buffer{}
+	var w io.Writer = buffer
+	var gz *gzip.Writer
+	if gzipped {
+		gz = gzip.NewWriter(buffer)
+		w = gz
+	}
+	tarball := tar.NewWriter(w)
+	Expect(tarball.WriteHeader(&tar.Header{Name: "./", Mode: 0755, Typeflag: tar.TypeDir})).To(Succeed())
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		Expect(tarball.WriteHeader(&tar.Header{Name: "./" + filepath.Dir(name) + "/", Mode: 0755, Typeflag: tar.TypeDir})).To(Succeed())
+		Expect(tarball.WriteHeader(&tar.Header{Name: "./" + name, Mode: 0644, Size: int64(len(files[name]))})).To(Succeed())
+		_, err := tarball.Write([]byte(files[name]))
+		Expect(err).NotTo(HaveOccurred())
+	}
+	Expect(tarball.Close()).To(Succeed())
+	if gz != nil {
+		Expect(gz.Close()).To(Succeed())
+	}
+	return buffer.Bytes()
+}
+
+func readTestTar(archive io.Reader, gzipped bool) (map[string]string, error) {
+	if gzipped {
+		gz, err := gzip.NewReader(archive)
+		if err != nil {
+			return nil, err
+		}
+		archive = gz
+	}
+	files := map[string]string{}
+	tarball := tar.NewReader(archive)
+	for {
+		header, err := tarball.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(tarball)
+		if err != nil {
+			return nil, err
+		}
+		files[header.Name] = string(data)
+	}
+}
+
+func archiveSize(files map[string]string) int {
+	size := 2 * 512
+	for _, data := range files {
+		size += 512 + (len(data)+511)/512*512
+	}
+	return size
+}
+
+func loadedImage(imageID string) <-chan engine.Progress {
+	progress := make(chan engine.Progress, 2)
+	progress <- engine.Progress{Stream: "Loaded image: some-ref:latest\n"}
+	progress <- engine.Progress{Aux: &engine.ProgressAux{ImageID: imageID}}
+	close(progress)
+	return progress
+}
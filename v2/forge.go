@@ -1,6 +1,9 @@
 package v2
 
 import (
+	"context"
+	"net"
+
 	"github.com/buildpack/forge/engine"
 )
 
@@ -24,10 +27,28 @@ type NetworkConfig struct {
 	ContainerPort string
 	HostIP        string
 	HostPort      string
+	Ports         []engine.PortBinding
 }
 
 //go:generate mockgen -package mocks -destination mocks/container.go github.com/buildpack/forge/engine Container
-//go:generate mockgen -package mocks -destination mocks/engine.go github.com/buildpack/forge Engine
+//go:generate mockgen -package mocks -destination mocks/image.go github.com/buildpack/forge/engine Image
+//go:generate mockgen -package mocks -destination mocks/engine.go github.com/buildpack/forge/v2 Engine
 type Engine interface {
 	NewContainer(config *engine.ContainerConfig) (engine.Container, error)
+	NewImage() engine.Image
+	EventsContext(ctx context.Context) <-chan engine.Event
+}
+
+func appURL(network *engine.NetworkInfo, containerPort string) (url string, ok bool) {
+	for _, port := range network.Ports {
+		if port.ContainerPort != containerPort || port.Protocol != "tcp" {
+			continue
+		}
+		host := port.HostIP
+		if host == "" || host == "0.0.0.0" || host == "::" {
+			host = "localhost"
+		}
+		return "http://" + net.JoinHostPort(host, port.HostPort), true
+	}
+	return "", false
 }
@@ -42,6 +42,8 @@ type ForwardConfig struct {
 	Details          *ForwardDetails
 	ContainerPort    string
 	HostIP, HostPort string
+	Ports            []engine.PortBinding
+	URL              chan<- string // receives the app URL once the network is ready
 	Wait             <-chan time.Time
 }
 
@@ -55,7 +57,7 @@ func NewForwarder(engine Engine) *Forwarder {
 func (f *Forwarder) Forward(config *ForwardConfig) (health <-chan string, done func(), id string, err error) {
 	output := internal.NewLockWriter(f.Logs)
 
-	netContr, err := f.engine.NewContainer(f.buildNetConfig(config.AppName, config.Stack, config.ContainerPort, config.HostIP, config.HostPort))
+	netContr, err := f.engine.NewContainer(f.buildNetConfig(config.AppName, config.Stack, config.ContainerPort, config.HostIP, config.HostPort, config.Ports))
 	if err != nil {
 		return nil, nil, "", err
 	}
@@ -64,7 +66,16 @@ func (f *Forwarder) Forward(config *ForwardConfig) (health <-chan string, done f
 		return nil, nil, "", err
 	}
 
-	containerConfig, err := f.buildConfig(config.Details, config.Stack, netContr.ID())
+	var url string
+	if config.URL != nil {
+		network, err := netContr.Network()
+		if err != nil {
+			return nil, nil, "", err
+		}
+		url, _ = appURL(network, config.ContainerPort)
+	}
+
+	containerConfig, err := f.buildConfig(config.AppName, config.Details, config.Stack, netContr.ID())
 	if err != nil {
 		return nil, nil, "", err
 	}
@@ -76,6 +87,14 @@ func (f *Forwarder) Forward(config *ForwardConfig) (health <-chan string, done f
 	prefix := config.Color("[%s tunnel] ", config.AppName)
 	wait := config.Wait
 	exit := make(chan struct{})
+	if url != "" {
+		go func() {
+			select {
+			case config.URL <- url:
+			case <-exit:
+			}
+		}()
+	}
 	go func() {
 		for {
 			select {
@@ -111,7 +130,7 @@ func (f *Forwarder) Forward(config *ForwardConfig) (health <-chan string, done f
 	return contr.HealthCheck(), done, netContr.ID(), nil
 }
 
-func (f *Forwarder) buildConfig(forward *ForwardDetails, stack, netID string) (*engine.ContainerConfig, error) {
+func (f *Forwarder) buildConfig(name string, forward *ForwardDetails, stack, netID string) (*engine.ContainerConfig, error) {
 	scriptBuf := &bytes.Buffer{}
 	tmpl := template.Must(template.New("").Parse(forwardScriptTmpl))
 	if err := tmpl.Execute(scriptBuf, forward); err != nil {
@@ -120,6 +139,8 @@ func (f *Forwarder) buildConfig(forward *ForwardDetails, stack, netID string) (*
 
 	return &engine.ContainerConfig{
 		Name:         "service",
+		AppName:      name,
+		Role:         engine.RoleTunnel,
 		Image:        stack,
 		Entrypoint:   []string{"/bin/bash", "-c", scriptBuf.String()},
 		NetContainer: netID,
@@ -130,15 +151,18 @@ func (f *Forwarder) buildConfig(forward *ForwardDetails, stack, netID string) (*
 	}, nil
 }
 
-func (f *Forwarder) buildNetConfig(name, stack, containerPort, hostIP, hostPort string) *engine.ContainerConfig {
+func (f *Forwarder) buildNetConfig(name, stack, containerPort, hostIP, hostPort string, ports []engine.PortBinding) *engine.ContainerConfig {
 	return &engine.ContainerConfig{
 		Name:       "network",
+		AppName:    name,
+		Role:       engine.RoleNetwork,
 		Hostname:   name,
 		Image:      stack,
 		Port:       containerPort,
 		Entrypoint: []string{"tail", "-f", "/dev/null"},
 		HostIP:     hostIP,
 		HostPort:   hostPort,
+		Ports:      ports,
 		Exit:       make(<-chan struct{}),
 	}
 }
@@ -45,6 +45,7 @@ var _ = Describe("Forwarder", func() {
 		It("should configure service tunnels and general app networking", func() {
 			mockHealth := make(<-chan string)
 			waiter := make(chan time.Time)
+			urls := make(chan string)
 			codeIdx := 0
 			config := &ForwardConfig{
 				AppName: "some-name",
@@ -74,24 +75,34 @@ var _ = Describe("Forwarder", func() {
 				ContainerPort: "300",
 				HostIP:        "some-ip",
 				HostPort:      "400",
+				Ports:         []engine.PortBinding{{Protocol: "udp", ContainerPort: "500"}},
+				URL:           urls,
 				Wait:          waiter,
 			}
 			mockEngine.EXPECT().NewContainer(gomock.Any()).Do(func(config *engine.ContainerConfig) {
 				Expect(config.Name).To(Equal("network"))
+				Expect(config.AppName).To(Equal("some-name"))
+				Expect(config.Role).To(Equal(engine.RoleNetwork))
 				Expect(config.Hostname).To(Equal("some-name"))
 				Expect(config.Image).To(Equal("some-stack"))
 				Expect(config.Port).To(Equal("300"))
 				Expect(config.Entrypoint).To(Equal([]string{"tail", "-f", "/dev/null"}))
 				Expect(config.HostIP).To(Equal("some-ip"))
 				Expect(config.HostPort).To(Equal("400"))
+				Expect(config.Ports).To(Equal([]engine.PortBinding{{Protocol: "udp", ContainerPort: "500"}}))
 				Expect(config.Exit).NotTo(BeNil())
 			}).Return(mockNetContainer, nil)
 
 			mockNetContainer.EXPECT().ID().Return("some-net-container").AnyTimes()
 			gomock.InOrder(
 				mockNetContainer.EXPECT().Background(),
+				mockNetContainer.EXPECT().Network().Return(&engine.NetworkInfo{
+					Ports: []engine.PortBinding{{Protocol: "tcp", ContainerPort: "300", HostIP: "127.0.0.1", HostPort: "400"}},
+				}, nil),
 				mockEngine.EXPECT().NewContainer(gomock.Any()).Do(func(config *engine.ContainerConfig) {
 					Expect(config.Name).To(Equal("service"))
+					Expect(config.AppName).To(Equal("some-name"))
+					Expect(config.Role).To(Equal(engine.RoleTunnel))
 					Expect(config.Image).To(Equal("some-stack"))
 					Expect(config.Entrypoint).To(HaveLen(3))
 					Expect(config.Entrypoint[0]).To(Equal("/bin/bash"))
@@ -111,6 +122,7 @@ var _ = Describe("Forwarder", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(health).To(Equal(mockHealth))
 			Expect(id).To(Equal("some-net-container"))
+			Eventually(urls).Should(Receive(Equal("http://127.0.0.1:400")))
 
 			gomock.InOrder(
 				mockContainer.EXPECT().StreamFileTo(gomock.Any(), "/tmp/ssh-code").Do(func(stream engine.Stream, _ string) {
@@ -0,0 +1,289 @@
+package v2
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	godigest "github.com/opencontainers/go-digest"
+
+	"github.com/buildpack/forge/engine"
+)
+
+var dropletModTime = time.Date(1980, time.January, 1, 0, 0, 1, 0, time.UTC)
+
+// dropletLayer is an uncompressed layer tarball containing a droplet extracted under a directory.
+// The same droplet always produces the same layer: entries are sorted and have fixed mtimes.
+type dropletLayer struct {
+	DiffID   godigest.Digest
+	Size     int64
+	Checksum string // of the droplet as provided
+	file     *os.File
+}
+
+type tarEntry struct {
+	header *tar.Header
+	offset int64
+}
+
+// newDropletLayer reads and closes a droplet, which should be created by engine.NewDigestStream.
+// The droplet files are owned by uid and gid.
+func newDropletLayer(droplet engine.Stream, dir string, uid, gid int) (*dropletLayer, error) {
+	src, err := ioutil.TempFile("", "forge-droplet")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(src.Name())
+	defer src.Close()
+	if err := decompress(src, droplet); err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(ioutil.Discard, droplet); err != nil {
+		return nil, err
+	}
+	if err := droplet.Close(); err != nil {
+		return nil, err
+	}
+
+	entries, err := readTarEntries(src, dir, uid, gid)
+	if err != nil {
+		return nil, err
+	}
+	layer, err := newLayer(func(w io.Writer) error {
+		return writeTarEntries(w, src, entries)
+	})
+	if err != nil {
+		return nil, err
+	}
+	layer.Checksum = droplet.Digest()
+	return layer, nil
+}
+
+// newLayer stores an uncompressed layer tarball in a temporary file.
+func newLayer(write func(io.Writer) error) (*dropletLayer, error) {
+	file, err := ioutil.TempFile("", "forge-layer")
+	if err != nil {
+		return nil, err
+	}
+	digester := godigest.Canonical.Digester()
+	if err := write(io.MultiWriter(file, digester.Hash())); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return &dropletLayer{
+		DiffID: digester.Digest(),
+		Size:   info.Size(),
+		file:   file,
+	}, nil
+}
+
+func (l *dropletLayer) Reader() io.Reader {
+	return io.NewSectionReader(l.file, 0, l.Size)
+}
+
+func (l *dropletLayer) Close() error {
+	defer os.Remove(l.file.Name())
+	return l.file.Close()
+}
+
+// decompress copies a tarball, gzipped or not, to dst uncompressed.
+func decompress(dst io.Writer, archive io.Reader) error {
+	in, err := uncompressed(archive)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, in)
+	return err
+}
+
+func uncompressed(archive io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(archive)
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(buffered)
+	}
+	return buffered, nil
+}
+
+// stackOwner returns the IDs of the user that runs a stack, given as user[:group] by name or ID.
+// Names are looked up in /etc/passwd and /etc/group of the stack, which open reads.
+func stackOwner(user string, open func(path string) (io.ReadCloser, error)) (uid, gid int, err error) {
+	if user == "" {
+		return 0, 0, nil
+	}
+	name, group := user, ""
+	if i := strings.IndexByte(user, ':'); i >= 0 {
+		name, group = user[:i], user[i+1:]
+	}
+	if name == "" {
+		name = "0"
+	}
+	uid, uidErr := strconv.Atoi(name)
+	if uidErr != nil || group == "" {
+		entry, err := lookupEntry(open, "/etc/passwd", name)
+		if err != nil {
+			return 0, 0, err
+		}
+		switch {
+		case entry != nil:
+			if uid, err = strconv.Atoi(entry[2]); err != nil {
+				return 0, 0, fmt.Errorf("invalid user %s in stack: %s", name, err)
+			}
+			if gid, err = strconv.Atoi(entry[3]); err != nil {
+				return 0, 0, fmt.Errorf("invalid user %s in stack: %s", name, err)
+			}
+		case uidErr != nil:
+			return 0, 0, fmt.Errorf("user %s not found in stack", name)
+		}
+	}
+	if group == "" {
+		return uid, gid, nil
+	}
+	if gid, err = strconv.Atoi(group); err == nil {
+		return uid, gid, nil
+	}
+	entry, err := lookupEntry(open, "/etc/group", group)
+	if err != nil {
+		return 0, 0, err
+	}
+	if entry == nil {
+		return 0, 0, fmt.Errorf("group %s not found in stack", group)
+	}
+	if gid, err = strconv.Atoi(entry[2]); err != nil {
+		return 0, 0, fmt.Errorf("invalid group %s in stack: %s", group, err)
+	}
+	return uid, gid, nil
+}
+
+// lookupEntry returns the fields of the passwd or group entry with the given name or ID, or nil if there is none.
+func lookupEntry(open func(path string) (io.ReadCloser, error), path, key string) ([]string, error) {
+	file, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 4 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if fields[0] == key || fields[2] == key {
+			return fields, nil
+		}
+	}
+	return nil, scanner.Err()
+}
+
+// readTarEntries indexes a tarball by entry name after moving it under dir.
+// Parent directories of dir that are missing from the tarball are owned by root.
+func readTarEntries(archive io.ReaderAt, dir string, uid, gid int) (map[string]tarEntry, error) {
+	entries := map[string]tarEntry{}
+	prefix := strings.Trim(path.Clean("/"+dir), "/")
+	if prefix != "" {
+		parts := strings.Split(prefix, "/")
+		for i := range parts {
+			name := strings.Join(parts[:i+1], "/") + "/"
+			entries[name] = tarEntry{header: &tar.Header{
+				Name:     name,
+				Mode:     0755,
+				Typeflag: tar.TypeDir,
+				ModTime:  dropletModTime,
+			}}
+		}
+	}
+
+	// the counter hides io.Seeker so that it sees every byte before the data of each entry
+	counter := &countingReader{r: io.NewSectionReader(archive, 0, 1<<62)}
+	tarball := tar.NewReader(counter)
+	for {
+		header, err := tarball.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimPrefix(path.Join("/", prefix, header.Name), "/")
+		if name == "" {
+			continue
+		}
+		linkname := header.Linkname
+		switch header.Typeflag {
+		case tar.TypeDir:
+			name += "/"
+		case tar.TypeLink:
+			linkname = strings.TrimPrefix(path.Join("/", prefix, linkname), "/")
+		case tar.TypeReg, tar.TypeRegA:
+			header.Typeflag = tar.TypeReg
+		case tar.TypeSymlink, tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		default:
+			return nil, errors.New("unsupported tar entry: " + header.Name)
+		}
+		entries[name] = tarEntry{
+			header: &tar.Header{
+				Name:     name,
+				Linkname: linkname,
+				Size:     header.Size,
+				Mode:     header.Mode,
+				Typeflag: header.Typeflag,
+				Uid:      uid,
+				Gid:      gid,
+				ModTime:  dropletModTime,
+				Devmajor: header.Devmajor,
+				Devminor: header.Devminor,
+			},
+			offset: counter.n,
+		}
+	}
+}
+
+func writeTarEntries(w io.Writer, archive io.ReaderAt, entries map[string]tarEntry) error {
+	var names []string
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tarball := tar.NewWriter(w)
+	for _, name := range names {
+		entry := entries[name]
+		if err := tarball.WriteHeader(entry.header); err != nil {
+			return err
+		}
+		if entry.header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if _, err := io.Copy(tarball, io.NewSectionReader(archive, entry.offset, entry.header.Size)); err != nil {
+			return err
+		}
+	}
+	return tarball.Close()
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,111 @@
+package v2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/buildpack/forge/engine"
+)
+
+// Version is recorded in the metadata of exported images.
+// Set it with -ldflags "-X github.com/buildpack/forge/v2.Version=<version>".
+var Version = "dev"
+
+// ImageMetadata is stored as JSON in the engine.LabelMetadata label of exported images:
+//
+//	{
+//	  "app": "some-app",
+//	  "stack": {"ref": "packs/cflinuxfs2:run", "id": "sha256:...", "digest": "packs/cflinuxfs2@sha256:..."},
+//	  "buildpacks": [{"key": "some-checksum", "name": "ruby", "version": "1.7.18"}],
+//	  "droplet": {"checksum": "sha256:..."},
+//	  "start_command": "bundle exec rackup",
+//	  "forge_version": "1.0.0"
+//	}
+//
+// The stack digest is omitted for stack images that were not pulled from a registry.
+type ImageMetadata struct {
+	App          string              `json:"app"`
+	Stack        StackMetadata       `json:"stack"`
+	Buildpacks   []BuildpackMetadata `json:"buildpacks"`
+	Droplet      DropletMetadata     `json:"droplet"`
+	StartCommand string              `json:"start_command"`
+	ForgeVersion string              `json:"forge_version"`
+}
+
+type StackMetadata struct {
+	Ref    string `json:"ref"`
+	ID     string `json:"id"`
+	Digest string `json:"digest,omitempty"`
+}
+
+type BuildpackMetadata struct {
+	Key     string `json:"key"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type DropletMetadata struct {
+	Checksum string `json:"checksum"`
+}
+
+// StagingMetadata describes the buildpacks used to stage a droplet.
+type StagingMetadata struct {
+	Buildpacks   []BuildpackMetadata
+	StartCommand string
+}
+
+// stagingResult is the result JSON written by the builder.
+type stagingResult struct {
+	ProcessTypes      map[string]string `json:"process_types"`
+	LifecycleMetadata struct {
+		Buildpacks []BuildpackMetadata `json:"buildpacks"`
+	} `json:"lifecycle_metadata"`
+}
+
+func readStagingMetadata(ctx context.Context, contr engine.Container, path string, metadata *StagingMetadata) error {
+	stream, err := contr.StreamFileFromContext(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	var result stagingResult
+	if err := json.NewDecoder(stream).Decode(&result); err != nil {
+		return fmt.Errorf("invalid staging result %s: %s", path, err)
+	}
+	metadata.Buildpacks = result.LifecycleMetadata.Buildpacks
+	metadata.StartCommand = result.ProcessTypes["web"]
+	return nil
+}
+
+func stackMetadata(ref string, stack *engine.ImageInfo) StackMetadata {
+	metadata := StackMetadata{Ref: ref, ID: stack.ID}
+	if len(stack.RepoDigests) > 0 {
+		metadata.Digest = stack.RepoDigests[0]
+	}
+	return metadata
+}
+
+func metadataLabel(app *AppConfig, stack StackMetadata, staging *StagingMetadata, dropletChecksum string) (string, error) {
+	metadata := ImageMetadata{
+		App:          app.Name,
+		Stack:        stack,
+		Buildpacks:   []BuildpackMetadata{},
+		Droplet:      DropletMetadata{Checksum: dropletChecksum},
+		StartCommand: app.Command,
+		ForgeVersion: Version,
+	}
+	if staging != nil {
+		if staging.Buildpacks != nil {
+			metadata.Buildpacks = staging.Buildpacks
+		}
+		if metadata.StartCommand == "" {
+			metadata.StartCommand = staging.StartCommand
+		}
+	}
+	label, err := json.Marshal(metadata)
+	if err != nil {
+		return "", err
+	}
+	return string(label), nil
+}
@@ -0,0 +1,61 @@
+package v2
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/buildpack/forge/engine"
+)
+
+type PullPolicy string
+
+const (
+	PullAlways       PullPolicy = "always"
+	PullIfNotPresent PullPolicy = "if-not-present"
+	PullNever        PullPolicy = "never"
+)
+
+type ImageNotFoundError struct {
+	Ref string
+}
+
+func (e *ImageNotFoundError) Error() string {
+	return fmt.Sprintf("image %s not found locally and pull policy is %s", e.Ref, PullNever)
+}
+
+func pullImage(ctx context.Context, image engine.Image, ref string, policy PullPolicy, progress chan<- engine.Progress) error {
+	switch policy {
+	case PullAlways:
+	case PullIfNotPresent, PullNever, "":
+		exists, err := image.ExistsContext(ctx, ref)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+		if policy == PullNever {
+			return &ImageNotFoundError{Ref: ref}
+		}
+	default:
+		return fmt.Errorf("invalid pull policy: %s", policy)
+	}
+
+	var pullErr error
+	for p := range image.PullContext(ctx, ref) {
+		if _, err := p.Status(); err != nil && pullErr == nil {
+			pullErr = err
+		}
+		if progress == nil {
+			continue
+		}
+		select {
+		case progress <- p:
+		case <-ctx.Done():
+		}
+	}
+	if pullErr != nil {
+		return pullErr
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,202 @@
+package v2
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/buildpack/forge/engine"
+)
+
+type RebaseConfig struct {
+	Image            string // exported by forge
+	Stack            string
+	PullPolicy       PullPolicy             // default: if-not-present
+	PullProgress     chan<- engine.Progress // receives stack image pull progress
+	TransferProgress chan<- engine.Progress // receives progress of saving the image
+	Ref              string                 // default: Image
+}
+
+func (e *Exporter) Rebase(config *RebaseConfig) (imageID string, err error) {
+	return e.RebaseContext(context.Background(), config)
+}
+
+// RebaseContext replaces the stack layers of an exported image without restaging.
+// The new stack must be from the same repository as the stack the image was exported on.
+func (e *Exporter) RebaseContext(ctx context.Context, config *RebaseConfig) (imageID string, err error) {
+	image := e.engine.NewImage()
+	app, err := image.InspectContext(ctx, config.Image)
+	if err != nil {
+		return "", err
+	}
+	var metadata ImageMetadata
+	if app.Labels[engine.LabelRole] != engine.RoleExport ||
+		json.Unmarshal([]byte(app.Labels[engine.LabelMetadata]), &metadata) != nil ||
+		len(app.Layers) == 0 {
+		return "", fmt.Errorf("image %s was not exported by forge", config.Image)
+	}
+	if err := pullImage(ctx, image, config.Stack, config.PullPolicy, config.PullProgress); err != nil {
+		return "", err
+	}
+	stack, err := image.InspectContext(ctx, config.Stack)
+	if err != nil {
+		return "", err
+	}
+	if repository(config.Stack) != repository(metadata.Stack.Ref) ||
+		stack.OS != app.OS || stack.Architecture != app.Architecture {
+		return "", fmt.Errorf("stack %s is not compatible with image %s exported on %s", config.Stack, config.Image, metadata.Stack.Ref)
+	}
+	oldStackEnv, err := stackEnv(ctx, image, metadata.Stack.ID)
+	if err != nil {
+		return "", err
+	}
+
+	layer, err := savedLayer(ctx, image, config.Image, app.Layers[len(app.Layers)-1], config.TransferProgress)
+	if err != nil {
+		return "", err
+	}
+	defer layer.Close()
+
+	metadata.Stack = stackMetadata(config.Stack, stack)
+	metadata.ForgeVersion = Version
+	label, err := json.Marshal(metadata)
+	if err != nil {
+		return "", err
+	}
+	ref := config.Ref
+	if ref == "" {
+		ref = config.Image
+	}
+	return loadImage(ctx, image, ref, stack, ocispec.ImageConfig{
+		Env:        rebaseEnv(app.Env, oldStackEnv, stack.Env),
+		Entrypoint: app.Entrypoint,
+		Cmd:        app.Cmd,
+		WorkingDir: app.WorkingDir,
+		Labels: mergeMaps(stack.Labels, map[string]string{
+			engine.LabelRole:     engine.RoleExport,
+			engine.LabelApp:      app.Labels[engine.LabelApp],
+			engine.LabelMetadata: string(label),
+		}),
+	}, layer, dropletHistory(app))
+}
+
+// dropletHistory returns how the droplet layer of an exported image was created.
+func dropletHistory(app *engine.ImageInfo) string {
+	if n := len(app.History); n > 0 && !app.History[n-1].EmptyLayer {
+		return app.History[n-1].CreatedBy
+	}
+	return "forge export"
+}
+
+// stackEnv returns the environment of a stack image, or nil if it was removed.
+func stackEnv(ctx context.Context, image engine.Image, ref string) ([]string, error) {
+	if exists, err := image.ExistsContext(ctx, ref); err != nil || !exists {
+		return nil, err
+	}
+	stack, err := image.InspectContext(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return stack.Env, nil
+}
+
+// rebaseEnv replaces variables set by the old stack with those of the new stack.
+// Without the old stack, all variables are kept as set in the image.
+func rebaseEnv(env, oldStackEnv, newStackEnv []string) []string {
+	fromStack := map[string]bool{}
+	for _, kv := range oldStackEnv {
+		fromStack[kv] = true
+	}
+	var appEnv []string
+	for _, kv := range env {
+		if !fromStack[kv] {
+			appEnv = append(appEnv, kv)
+		}
+	}
+	return mergeEnv(newStackEnv, appEnv)
+}
+
+// savedLayer extracts the top layer of a saved image in a single pass. Layers are kept
+// only if they have diffID, and the layer kept must be the top layer in manifest.json.
+func savedLayer(ctx context.Context, image engine.Image, ref, diffID string, progress chan<- engine.Progress) (*dropletLayer, error) {
+	saved, err := image.SaveContext(ctx, progress, ref)
+	if err != nil {
+		return nil, err
+	}
+	defer saved.Close()
+	var layer *dropletLayer
+	var name, top string
+	fail := func(err error) (*dropletLayer, error) {
+		if layer != nil {
+			layer.Close()
+		}
+		return nil, err
+	}
+	tarball := tar.NewReader(saved)
+	for {
+		header, err := tarball.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		switch entry := path.Clean(header.Name); {
+		case entry == "manifest.json":
+			var manifest []struct{ Layers []string }
+			if err := json.NewDecoder(tarball).Decode(&manifest); err != nil {
+				return fail(fmt.Errorf("invalid manifest in image %s: %s", ref, err))
+			}
+			if len(manifest) > 0 && len(manifest[0].Layers) > 0 {
+				layers := manifest[0].Layers
+				top = path.Clean(layers[len(layers)-1])
+			}
+		case layer == nil && savedLayerEntry(entry, diffID):
+			candidate, err := newLayer(func(w io.Writer) error {
+				_, err := io.Copy(w, tarball)
+				return err
+			})
+			if err != nil {
+				return fail(err)
+			}
+			if candidate.DiffID.String() != diffID {
+				candidate.Close()
+				continue
+			}
+			layer, name = candidate, entry
+		}
+	}
+	if layer == nil || name != top {
+		return fail(fmt.Errorf("layer %s not found in image %s", diffID, ref))
+	}
+	return layer, nil
+}
+
+// savedLayerEntry returns true if the entry called name may contain the layer with diffID.
+// Blobs in the OCI layout are named by digest, so they are only read if they match.
+func savedLayerEntry(name, diffID string) bool {
+	if path.Dir(name) == "blobs/sha256" {
+		return "sha256:"+path.Base(name) == diffID
+	}
+	return path.Base(name) == "layer.tar"
+}
+
+// repository removes the tag or digest from ref.
+func repository(ref string) string {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
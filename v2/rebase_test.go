@@ -0,0 +1,208 @@
+package v2_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/buildpack/forge/engine"
+	"github.com/buildpack/forge/mocks"
+	. "github.com/buildpack/forge/v2"
+)
+
+var _ = Describe("Exporter", func() {
+	var (
+		exporter   *Exporter
+		mockCtrl   *gomock.Controller
+		mockEngine *mocks.MockEngine
+		mockImage  *mocks.MockImage
+	)
+
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockEngine = mocks.NewMockEngine(mockCtrl)
+		mockImage = mocks.NewMockImage(mockCtrl)
+
+		mockEngine.EXPECT().NewImage().Return(mockImage).AnyTimes()
+
+		exporter = NewExporter(mockEngine)
+	})
+
+	AfterEach(func() {
+		mockCtrl.Finish()
+	})
+
+	Describe("#Rebase", func() {
+		var (
+			appInfo      *engine.ImageInfo
+			stackInfo    *engine.ImageInfo
+			dropletLayer []byte
+		)
+
+		BeforeEach(func() {
+			dropletLayer = testTar(false, map[string]string{"home/vcap/app/some-file": "some-app-data"})
+			appInfo = &engine.ImageInfo{
+				ID: "some-app-id",
+				Labels: map[string]string{
+					"some-label":     "some-old-value",
+					engine.LabelRole: engine.RoleExport,
+					engine.LabelApp:  "some-name",
+					engine.LabelMetadata: `{
+						"app": "some-name",
+						"stack": {"ref": "some-stack:1", "id": "some-old-stack-id"},
+						"buildpacks": [{"key": "some-checksum", "name": "some-buildpack"}],
+						"droplet": {"checksum": "sha256:some-droplet-checksum"},
+						"start_command": "some-command",
+						"forge_version": "some-version"
+					}`,
+				},
+				Env:          []string{"PATH=/usr/bin", "TEST_ENV_KEY=test-env-value"},
+				Entrypoint:   []string{"/packs/launcher"},
+				Cmd:          []string{"some-command"},
+				WorkingDir:   "/home/vcap/app",
+				OS:           "linux",
+				Architecture: "amd64",
+				Layers:       []string{"sha256:some-old-stack-layer", digest.FromBytes(dropletLayer).String()},
+			}
+			stackInfo = &engine.ImageInfo{
+				ID:           "some-stack-id",
+				RepoDigests:  []string{"some-stack@sha256:some-digest"},
+				Labels:       map[string]string{"some-label": "some-value"},
+				Env:          []string{"PATH=/usr/local/bin:/usr/bin"},
+				User:         "vcap",
+				OS:           "linux",
+				Architecture: "amd64",
+				Layers:       []string{"sha256:some-stack-layer"},
+			}
+		})
+
+		It("should replace the stack layers and keep the droplet layer and config", func() {
+			saved := testTar(false, map[string]string{
+				"some-old-stack-layer/layer.tar": "some-old-stack-layer",
+				"some-droplet-layer/layer.tar":   string(dropletLayer),
+				"manifest.json":                  `[{"Layers": ["some-old-stack-layer/layer.tar", "some-droplet-layer/layer.tar"]}]`,
+			})
+			var files map[string]string
+			gomock.InOrder(
+				mockImage.EXPECT().InspectContext(gomock.Any(), "some-app").Return(appInfo, nil),
+				mockImage.EXPECT().ExistsContext(gomock.Any(), "some-stack:2").Return(true, nil),
+				mockImage.EXPECT().InspectContext(gomock.Any(), "some-stack:2").Return(stackInfo, nil),
+				mockImage.EXPECT().ExistsContext(gomock.Any(), "some-old-stack-id").Return(true, nil),
+				mockImage.EXPECT().InspectContext(gomock.Any(), "some-old-stack-id").Return(&engine.ImageInfo{Env: []string{"PATH=/usr/bin"}}, nil),
+				mockImage.EXPECT().SaveContext(gomock.Any(), nil, "some-app").
+					Return(engine.NewStream(ioutil.NopCloser(bytes.NewReader(saved)), int64(len(saved))), nil),
+				mockImage.EXPECT().LoadContext(gomock.Any(), gomock.Any()).Do(func(_ context.Context, archive engine.Stream) {
+					var err error
+					files, err = readTestTar(archive, false)
+					Expect(err).NotTo(HaveOccurred())
+				}).Return(loadedImage("some-image-id")),
+			)
+			Expect(exporter.Rebase(&RebaseConfig{Image: "some-app", Stack: "some-stack:2"})).To(Equal("some-image-id"))
+
+			var manifest []struct {
+				Config   string
+				RepoTags []string
+				Layers   []string
+			}
+			Expect(json.Unmarshal([]byte(files["manifest.json"]), &manifest)).To(Succeed())
+			Expect(manifest).To(HaveLen(1))
+			Expect(manifest[0].RepoTags).To(Equal([]string{"some-app:latest"}))
+			Expect(manifest[0].Layers).To(Equal([]string{
+				"some-stack-layer/layer.tar",
+				digest.FromBytes(dropletLayer).Hex() + "/layer.tar",
+			}))
+			Expect(files[manifest[0].Layers[1]]).To(Equal(string(dropletLayer)))
+
+			var image ocispec.Image
+			Expect(json.Unmarshal([]byte(files[manifest[0].Config]), &image)).To(Succeed())
+			Expect(image.Config.User).To(Equal("vcap"))
+			Expect(image.Config.Env).To(Equal([]string{"PATH=/usr/local/bin:/usr/bin", "TEST_ENV_KEY=test-env-value"}))
+			Expect(image.Config.Entrypoint).To(Equal([]string{"/packs/launcher"}))
+			Expect(image.Config.Cmd).To(Equal([]string{"some-command"}))
+			Expect(image.Config.WorkingDir).To(Equal("/home/vcap/app"))
+			Expect(image.Config.Labels).To(HaveKeyWithValue("some-label", "some-value"))
+			Expect(image.Config.Labels).To(HaveKeyWithValue(engine.LabelRole, engine.RoleExport))
+			Expect(image.Config.Labels).To(HaveKeyWithValue(engine.LabelApp, "some-name"))
+			Expect(image.Config.Labels[engine.LabelMetadata]).To(MatchJSON(`{
+				"app": "some-name",
+				"stack": {"ref": "some-stack:2", "id": "some-stack-id", "digest": "some-stack@sha256:some-digest"},
+				"buildpacks": [{"key": "some-checksum", "name": "some-buildpack"}],
+				"droplet": {"checksum": "sha256:some-droplet-checksum"},
+				"start_command": "some-command",
+				"forge_version": "dev"
+			}`))
+			Expect(image.RootFS.DiffIDs).To(Equal([]digest.Digest{
+				"sha256:some-stack-layer",
+				digest.FromBytes(dropletLayer),
+			}))
+		})
+
+		It("should read the droplet layer from images saved in the OCI layout", func() {
+			dropletBlob := "blobs/sha256/" + digest.FromBytes(dropletLayer).Hex()
+			saved := testTar(false, map[string]string{
+				"blobs/sha256/some-old-stack-layer": "some-old-stack-layer",
+				dropletBlob:                         string(dropletLayer),
+				"index.json":                        "{}",
+				"manifest.json":                     `[{"Layers": ["blobs/sha256/some-old-stack-layer", "` + dropletBlob + `"]}]`,
+			})
+			var files map[string]string
+			gomock.InOrder(
+				mockImage.EXPECT().InspectContext(gomock.Any(), "some-app").Return(appInfo, nil),
+				mockImage.EXPECT().ExistsContext(gomock.Any(), "some-stack:2").Return(true, nil),
+				mockImage.EXPECT().InspectContext(gomock.Any(), "some-stack:2").Return(stackInfo, nil),
+				mockImage.EXPECT().ExistsContext(gomock.Any(), "some-old-stack-id").Return(false, nil),
+				mockImage.EXPECT().SaveContext(gomock.Any(), nil, "some-app").
+					Return(engine.NewStream(ioutil.NopCloser(bytes.NewReader(saved)), int64(len(saved))), nil),
+				mockImage.EXPECT().LoadContext(gomock.Any(), gomock.Any()).Do(func(_ context.Context, archive engine.Stream) {
+					var err error
+					files, err = readTestTar(archive, false)
+					Expect(err).NotTo(HaveOccurred())
+				}).Return(loadedImage("some-image-id")),
+			)
+			Expect(exporter.Rebase(&RebaseConfig{Image: "some-app", Stack: "some-stack:2"})).To(Equal("some-image-id"))
+			Expect(files[digest.FromBytes(dropletLayer).Hex()+"/layer.tar"]).To(Equal(string(dropletLayer)))
+		})
+
+		It("should return an error when the top layer of the saved image does not match", func() {
+			saved := testTar(false, map[string]string{
+				"some-old-stack-layer/layer.tar": "some-old-stack-layer",
+				"some-droplet-layer/layer.tar":   "some-other-layer",
+				"manifest.json":                  `[{"Layers": ["some-old-stack-layer/layer.tar", "some-droplet-layer/layer.tar"]}]`,
+			})
+			gomock.InOrder(
+				mockImage.EXPECT().InspectContext(gomock.Any(), "some-app").Return(appInfo, nil),
+				mockImage.EXPECT().ExistsContext(gomock.Any(), "some-stack:2").Return(true, nil),
+				mockImage.EXPECT().InspectContext(gomock.Any(), "some-stack:2").Return(stackInfo, nil),
+				mockImage.EXPECT().ExistsContext(gomock.Any(), "some-old-stack-id").Return(false, nil),
+				mockImage.EXPECT().SaveContext(gomock.Any(), nil, "some-app").
+					Return(engine.NewStream(ioutil.NopCloser(bytes.NewReader(saved)), int64(len(saved))), nil),
+			)
+			_, err := exporter.Rebase(&RebaseConfig{Image: "some-app", Stack: "some-stack:2"})
+			Expect(err).To(MatchError("layer " + digest.FromBytes(dropletLayer).String() + " not found in image some-app"))
+		})
+
+		It("should return an error when the image was not exported by forge", func() {
+			delete(appInfo.Labels, engine.LabelMetadata)
+			mockImage.EXPECT().InspectContext(gomock.Any(), "some-app").Return(appInfo, nil)
+			_, err := exporter.Rebase(&RebaseConfig{Image: "some-app", Stack: "some-stack:2"})
+			Expect(err).To(MatchError("image some-app was not exported by forge"))
+		})
+
+		It("should return an error when the stack is not compatible", func() {
+			gomock.InOrder(
+				mockImage.EXPECT().InspectContext(gomock.Any(), "some-app").Return(appInfo, nil),
+				mockImage.EXPECT().ExistsContext(gomock.Any(), "some-other-stack").Return(true, nil),
+				mockImage.EXPECT().InspectContext(gomock.Any(), "some-other-stack").Return(stackInfo, nil),
+			)
+			_, err := exporter.Rebase(&RebaseConfig{Image: "some-app", Stack: "some-other-stack"})
+			Expect(err).To(MatchError("stack some-other-stack is not compatible with image some-app exported on some-stack:1"))
+		})
+	})
+})
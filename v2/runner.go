@@ -1,9 +1,11 @@
 package v2
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"regexp"
 	"strconv"
@@ -24,23 +26,35 @@ const runScript = `
 
 var bytesPattern = regexp.MustCompile(`(?i)^(-?\d+)([KMGT])B?$`)
 
+const defaultStopTimeout = 10 * time.Second
+
 type Runner struct {
-	Logs   io.Writer
-	TTY    engine.TTY
-	engine Engine
+	Logs    io.Writer
+	LogSink engine.LogSink
+	TTY     engine.TTY
+	Exit    <-chan struct{} // exit of the engine, see RunConfig.Exit
+	engine  Engine
 }
 
 type RunConfig struct {
-	Droplet       engine.Stream
-	Stack         string
-	AppDir        string
-	OutputDir     string
-	WorkingDir    string
-	Shell         bool
-	Restart       <-chan time.Time
-	Color         Colorizer
-	AppConfig     *AppConfig
-	NetworkConfig *NetworkConfig
+	Droplet          engine.Stream
+	DropletDigest    string // optional, expected SHA-256 of Droplet
+	Stack            string
+	PullPolicy       PullPolicy             // default: if-not-present
+	PullProgress     chan<- engine.Progress // receives stack image pull progress
+	TransferProgress chan<- engine.Progress // receives droplet transfer progress
+	AppDir           string
+	OutputDir        string
+	WorkingDir       string
+	Shell            bool
+	Restart          <-chan time.Time
+	Exit             <-chan struct{} // stops the app gracefully when closed, as does Runner.Exit if set
+	StopTimeout      time.Duration   // default: 10 seconds
+	Stats            chan<- engine.Stats
+	URL              chan<- string // receives the app URL each time it starts
+	Color            Colorizer
+	AppConfig        *AppConfig
+	NetworkConfig    *NetworkConfig
 }
 
 func NewRunner(engine Engine) *Runner {
@@ -55,6 +69,10 @@ func NewRunner(engine Engine) *Runner {
 }
 
 func (r *Runner) Run(config *RunConfig) (status int64, err error) {
+	return r.RunContext(context.Background(), config)
+}
+
+func (r *Runner) RunContext(ctx context.Context, config *RunConfig) (status int64, err error) {
 	var binds []string
 	if config.AppDir != "" {
 		binds = []string{config.AppDir + ":/tmp/local"}
@@ -63,23 +81,154 @@ func (r *Runner) Run(config *RunConfig) (status int64, err error) {
 	if err != nil {
 		return 0, err
 	}
+	if config.Exit != nil && r.Exit != nil {
+		// stopOnExit stops the app gracefully when either exit is closed,
+		// otherwise the container is stopped abruptly when the engine exits
+		containerConfig.Exit = make(<-chan struct{})
+	}
+	if err := pullImage(ctx, r.engine.NewImage(), config.Stack, config.PullPolicy, config.PullProgress); err != nil {
+		return 0, err
+	}
 	contr, err := r.engine.NewContainer(containerConfig)
 	if err != nil {
 		return 0, err
 	}
 	defer contr.Close()
 
-	if err := contr.StreamTarTo(config.Droplet, config.OutputDir); err != nil {
+	droplet := engine.NewProgressStream(engine.NewDigestStream(config.Droplet, config.DropletDigest), "droplet", config.TransferProgress)
+	defer droplet.Close()
+	if err := contr.UploadTarToContext(ctx, droplet, config.OutputDir); err != nil {
+		return 0, err
+	}
+	// the upload may stop at the end of the tarball, so read the rest to verify the digest
+	if err := droplet.Out(ioutil.Discard); err != nil {
 		return 0, err
 	}
+	if config.Exit != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		go r.stopOnExit(ctx, contr, config, cancel)
+	}
 	color := config.Color("[%s] ", config.AppConfig.Name)
 	if !config.Shell {
-		return contr.Start(color, r.Logs, config.Restart)
+		wait := r.watchEvents(ctx, contr, config, color)
+		status, err := contr.StartContext(ctx, color, r.Logs, config.Restart)
+		wait(status)
+		return status, err
 	}
 	if err := contr.Background(); err != nil {
 		return 0, err
 	}
-	return 0, contr.Shell(r.TTY, "/packs/shell")
+	if config.Stats != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		go r.forwardStats(ctx, contr, config.Stats, color)
+	}
+	return 0, contr.ShellContext(ctx, r.TTY, "/packs/shell")
+}
+
+func (r *Runner) stopOnExit(ctx context.Context, contr engine.Container, config *RunConfig, cancel func()) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-config.Exit:
+	case <-r.Exit:
+	}
+	timeout := config.StopTimeout
+	if timeout == 0 {
+		timeout = defaultStopTimeout
+	}
+	if err := contr.Stop(timeout); err != nil {
+		fmt.Fprintf(r.Logs, "Error stopping app: %s\n", err)
+	}
+	if config.Restart != nil || config.Shell {
+		cancel()
+	}
+}
+
+func (r *Runner) watchEvents(ctx context.Context, contr engine.Container, config *RunConfig, color string) (wait func(status int64)) {
+	ctx, cancel := context.WithCancel(ctx)
+	events := r.engine.EventsContext(ctx)
+	id := contr.ID()
+	died := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stats := config.Stats
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				if event.ContainerID != id {
+					continue
+				}
+				switch event.Action {
+				case engine.EventStart:
+					if stats != nil {
+						go r.forwardStats(ctx, contr, stats, color)
+						stats = nil
+					}
+					if config.URL != nil {
+						r.sendURL(ctx, contr, config.NetworkConfig.ContainerPort, config.URL, color)
+					}
+				case engine.EventOOM:
+					fmt.Fprintf(r.Logs, "%sApp crashed: out of memory\n", color)
+				case engine.EventDie:
+					select {
+					case died <- struct{}{}:
+					default:
+					}
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return func(status int64) {
+		if status != 0 && status != 128 {
+			select {
+			case <-died:
+			case <-done:
+			case <-time.After(time.Second):
+			}
+		}
+		cancel()
+		<-done
+	}
+}
+
+func (r *Runner) sendURL(ctx context.Context, contr engine.Container, port string, urls chan<- string, color string) {
+	network, err := contr.NetworkContext(ctx)
+	if err != nil {
+		fmt.Fprintf(r.Logs, "%sError: %s\n", color, err)
+		return
+	}
+	url, ok := appURL(network, port)
+	if !ok {
+		return
+	}
+	select {
+	case urls <- url:
+	case <-ctx.Done():
+	}
+}
+
+func (r *Runner) forwardStats(ctx context.Context, contr engine.Container, out chan<- engine.Stats, color string) {
+	for stats := range contr.StatsContext(ctx) {
+		if stats.Err != nil {
+			fmt.Fprintf(r.Logs, "%sError reading stats: %s\n", color, stats.Err)
+			return
+		}
+		select {
+		case out <- stats:
+		case <-ctx.Done():
+			return
+		}
+	}
 }
 
 func (r *Runner) buildConfig(app *AppConfig, net *NetworkConfig, binds []string, workingDir, stack string) (*engine.ContainerConfig, error) {
@@ -116,6 +265,8 @@ func (r *Runner) buildConfig(app *AppConfig, net *NetworkConfig, binds []string,
 
 	return &engine.ContainerConfig{
 		Name:       app.Name,
+		AppName:    app.Name,
+		Role:       engine.RoleRun,
 		Hostname:   app.Name,
 		Env:        mapToEnv(mergeMaps(env, app.RunningEnv, app.Env)),
 		Image:      stack,
@@ -127,8 +278,10 @@ func (r *Runner) buildConfig(app *AppConfig, net *NetworkConfig, binds []string,
 		NetContainer: net.ContainerID,
 		HostIP:       net.HostIP,
 		HostPort:     net.HostPort,
+		Ports:        net.Ports,
 		Memory:       mem * 1024 * 1024,
 		DiskQuota:    disk * 1024 * 1024,
+		LogSink:      r.LogSink,
 	}, nil
 }
 
@@ -2,12 +2,17 @@ package v2_test
 
 import (
 	"bytes"
+	"errors"
+	"io/ioutil"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/opencontainers/go-digest"
 
 	"github.com/buildpack/forge/engine"
 	"github.com/buildpack/forge/mocks"
@@ -19,14 +24,19 @@ var _ = Describe("Runner", func() {
 		runner        *Runner
 		mockCtrl      *gomock.Controller
 		mockEngine    *mocks.MockEngine
+		mockImage     *mocks.MockImage
 		mockContainer *mocks.MockContainer
 	)
 
 	BeforeEach(func() {
 		mockCtrl = gomock.NewController(GinkgoT())
 		mockEngine = mocks.NewMockEngine(mockCtrl)
+		mockImage = mocks.NewMockImage(mockCtrl)
 		mockContainer = mocks.NewMockContainer(mockCtrl)
 
+		mockEngine.EXPECT().NewImage().Return(mockImage).AnyTimes()
+		mockImage.EXPECT().ExistsContext(gomock.Any(), gomock.Any()).Return(true, nil).AnyTimes()
+
 		runner = NewRunner(mockEngine)
 		runner.Logs = bytes.NewBufferString("some-logs")
 	})
@@ -38,7 +48,7 @@ var _ = Describe("Runner", func() {
 	Describe("#Run", func() {
 		It("should run the droplet in a container using the launcher", func() {
 			config := &RunConfig{
-				Droplet:    engine.NewStream(mockReadCloser{Value: "some-droplet"}, 100),
+				Droplet:    engine.NewStream(ioutil.NopCloser(bytes.NewBufferString("some-droplet")), 12),
 				Stack:      "some-stack",
 				AppDir:     "some-app-dir",
 				OutputDir:  "/home/vcap",
@@ -70,10 +80,13 @@ var _ = Describe("Runner", func() {
 					HostIP:      "some-ip",
 					HostPort:    "400",
 					ContainerID: "some-net-container",
+					Ports:       []engine.PortBinding{{ContainerPort: "500"}},
 				},
 			}
 			mockEngine.EXPECT().NewContainer(gomock.Any()).Do(func(config *engine.ContainerConfig) {
 				Expect(config.Name).To(Equal("some-name"))
+				Expect(config.AppName).To(Equal("some-name"))
+				Expect(config.Role).To(Equal(engine.RoleRun))
 				Expect(config.Hostname).To(Equal("some-name"))
 				sort.Strings(config.Env)
 				Expect(config.Env).To(Equal([]string{
@@ -95,19 +108,339 @@ var _ = Describe("Runner", func() {
 				Expect(config.NetContainer).To(Equal("some-net-container"))
 				Expect(config.HostIP).To(Equal("some-ip"))
 				Expect(config.HostPort).To(Equal("400"))
+				Expect(config.Ports).To(Equal([]engine.PortBinding{{ContainerPort: "500"}}))
 				Expect(config.Memory).To(Equal(int64(512 * 1024 * 1024)))
 				Expect(config.DiskQuota).To(Equal(int64(1024 * 1024 * 1024)))
 			}).Return(mockContainer, nil)
 
+			mockEngine.EXPECT().EventsContext(gomock.Any()).Return(noEvents())
+			mockContainer.EXPECT().ID().Return("some-id").AnyTimes()
+
 			gomock.InOrder(
-				mockContainer.EXPECT().StreamTarTo(config.Droplet, "/home/vcap"),
-				mockContainer.EXPECT().Start("[some-name] % ", runner.Logs, config.Restart).Return(int64(100), nil),
+				mockContainer.EXPECT().UploadTarToContext(gomock.Any(), gomock.Any(), "/home/vcap"),
+				mockContainer.EXPECT().StartContext(gomock.Any(), "[some-name] % ", runner.Logs, config.Restart).Return(int64(100), nil),
 				mockContainer.EXPECT().Close(),
 			)
 
 			Expect(runner.Run(config)).To(Equal(int64(100)))
 		})
 
+		It("should stop the app gracefully when signaled to exit", func() {
+			exit := make(chan struct{})
+			stopped := make(chan struct{})
+			runner.Exit = make(chan struct{})
+			config := &RunConfig{
+				Droplet:     engine.NewStream(ioutil.NopCloser(bytes.NewBufferString("some-droplet")), 12),
+				Stack:       "some-stack",
+				OutputDir:   "/home/vcap",
+				Exit:        exit,
+				StopTimeout: 5 * time.Second,
+				Color:       percentColor,
+				AppConfig:   &AppConfig{Name: "some-name"},
+				NetworkConfig: &NetworkConfig{
+					HostIP:   "some-ip",
+					HostPort: "400",
+				},
+			}
+			mockEngine.EXPECT().NewContainer(gomock.Any()).Do(func(config *engine.ContainerConfig) {
+				Expect(config.Exit).NotTo(BeNil())
+			}).Return(mockContainer, nil)
+
+			mockEngine.EXPECT().EventsContext(gomock.Any()).Return(noEvents())
+			mockContainer.EXPECT().ID().Return("some-id").AnyTimes()
+
+			gomock.InOrder(
+				mockContainer.EXPECT().UploadTarToContext(gomock.Any(), gomock.Any(), "/home/vcap"),
+				mockContainer.EXPECT().StartContext(gomock.Any(), "[some-name] % ", runner.Logs, nil).Do(func(_, _, _, _ interface{}) {
+					close(exit)
+					<-stopped
+				}).Return(int64(143), nil),
+				mockContainer.EXPECT().Close(),
+			)
+			mockContainer.EXPECT().Stop(5 * time.Second).Do(func(_ time.Duration) {
+				close(stopped)
+			})
+
+			Expect(runner.Run(config)).To(Equal(int64(143)))
+		})
+
+		It("should stop the app gracefully when the engine exits", func() {
+			exit := make(chan struct{})
+			stopped := make(chan struct{})
+			runner.Exit = exit
+			config := &RunConfig{
+				Droplet:     engine.NewStream(ioutil.NopCloser(bytes.NewBufferString("some-droplet")), 12),
+				Stack:       "some-stack",
+				OutputDir:   "/home/vcap",
+				Exit:        make(chan struct{}),
+				StopTimeout: 5 * time.Second,
+				Color:       percentColor,
+				AppConfig:   &AppConfig{Name: "some-name"},
+				NetworkConfig: &NetworkConfig{
+					HostIP:   "some-ip",
+					HostPort: "400",
+				},
+			}
+			mockEngine.EXPECT().NewContainer(gomock.Any()).Do(func(config *engine.ContainerConfig) {
+				Expect(config.Exit).NotTo(BeNil())
+			}).Return(mockContainer, nil)
+
+			mockEngine.EXPECT().EventsContext(gomock.Any()).Return(noEvents())
+			mockContainer.EXPECT().ID().Return("some-id").AnyTimes()
+
+			gomock.InOrder(
+				mockContainer.EXPECT().UploadTarToContext(gomock.Any(), gomock.Any(), "/home/vcap"),
+				mockContainer.EXPECT().StartContext(gomock.Any(), "[some-name] % ", runner.Logs, nil).Do(func(_, _, _, _ interface{}) {
+					close(exit)
+					<-stopped
+				}).Return(int64(143), nil),
+				mockContainer.EXPECT().Close(),
+			)
+			mockContainer.EXPECT().Stop(5 * time.Second).Do(func(_ time.Duration) {
+				close(stopped)
+			})
+
+			Expect(runner.Run(config)).To(Equal(int64(143)))
+		})
+
+		It("should leave the engine exit to the container when the runner does not have it", func() {
+			config := &RunConfig{
+				Droplet:   engine.NewStream(ioutil.NopCloser(bytes.NewBufferString("some-droplet")), 12),
+				Stack:     "some-stack",
+				OutputDir: "/home/vcap",
+				Exit:      make(chan struct{}),
+				Color:     percentColor,
+				AppConfig: &AppConfig{Name: "some-name"},
+				NetworkConfig: &NetworkConfig{
+					HostIP:   "some-ip",
+					HostPort: "400",
+				},
+			}
+			mockEngine.EXPECT().NewContainer(gomock.Any()).Do(func(config *engine.ContainerConfig) {
+				Expect(config.Exit).To(BeNil())
+			}).Return(mockContainer, nil)
+
+			mockEngine.EXPECT().EventsContext(gomock.Any()).Return(noEvents())
+			mockContainer.EXPECT().ID().Return("some-id").AnyTimes()
+
+			gomock.InOrder(
+				mockContainer.EXPECT().UploadTarToContext(gomock.Any(), gomock.Any(), "/home/vcap"),
+				mockContainer.EXPECT().StartContext(gomock.Any(), "[some-name] % ", runner.Logs, nil).Return(int64(0), nil),
+				mockContainer.EXPECT().Close(),
+			)
+
+			Expect(runner.Run(config)).To(Equal(int64(0)))
+		})
+
+		It("should forward resource usage stats while the app runs", func() {
+			statsIn := make(chan engine.Stats, 1)
+			statsOut := make(chan engine.Stats, 1)
+			config := &RunConfig{
+				Droplet:   engine.NewStream(ioutil.NopCloser(bytes.NewBufferString("some-droplet")), 12),
+				Stack:     "some-stack",
+				OutputDir: "/home/vcap",
+				Stats:     statsOut,
+				Color:     percentColor,
+				AppConfig: &AppConfig{Name: "some-name"},
+				NetworkConfig: &NetworkConfig{
+					HostIP:   "some-ip",
+					HostPort: "400",
+				},
+			}
+			mockEngine.EXPECT().NewContainer(gomock.Any()).Return(mockContainer, nil)
+
+			events := make(chan engine.Event, 2)
+			mockEngine.EXPECT().EventsContext(gomock.Any()).Return((<-chan engine.Event)(events))
+			mockContainer.EXPECT().ID().Return("some-id").AnyTimes()
+
+			gomock.InOrder(
+				mockContainer.EXPECT().UploadTarToContext(gomock.Any(), gomock.Any(), "/home/vcap"),
+				mockContainer.EXPECT().StartContext(gomock.Any(), "[some-name] % ", runner.Logs, nil).Do(func(_, _, _, _ interface{}) {
+					events <- engine.Event{Action: engine.EventStart, ContainerID: "some-id"}
+					events <- engine.Event{Action: engine.EventStart, ContainerID: "some-id"}
+					statsIn <- engine.Stats{MemoryUsage: 100}
+					Eventually(statsOut).Should(Receive(Equal(engine.Stats{MemoryUsage: 100})))
+				}).Return(int64(0), nil),
+				mockContainer.EXPECT().Close(),
+			)
+			mockContainer.EXPECT().StatsContext(gomock.Any()).Return((<-chan engine.Stats)(statsIn))
+
+			Expect(runner.Run(config)).To(Equal(int64(0)))
+		})
+
+		It("should log errors reading resource usage stats", func() {
+			statsIn := make(chan engine.Stats, 1)
+			statsIn <- engine.Stats{Err: errors.New("some-error")}
+			close(statsIn)
+			config := &RunConfig{
+				Droplet:   engine.NewStream(ioutil.NopCloser(bytes.NewBufferString("some-droplet")), 12),
+				Stack:     "some-stack",
+				OutputDir: "/home/vcap",
+				Stats:     make(chan engine.Stats),
+				Color:     percentColor,
+				AppConfig: &AppConfig{Name: "some-name"},
+				NetworkConfig: &NetworkConfig{
+					HostIP:   "some-ip",
+					HostPort: "400",
+				},
+			}
+			mockEngine.EXPECT().NewContainer(gomock.Any()).Return(mockContainer, nil)
+
+			events := make(chan engine.Event, 1)
+			mockEngine.EXPECT().EventsContext(gomock.Any()).Return((<-chan engine.Event)(events))
+			mockContainer.EXPECT().ID().Return("some-id").AnyTimes()
+			logs := gbytes.NewBuffer()
+			runner.Logs = logs
+
+			gomock.InOrder(
+				mockContainer.EXPECT().UploadTarToContext(gomock.Any(), gomock.Any(), "/home/vcap"),
+				mockContainer.EXPECT().StartContext(gomock.Any(), "[some-name] % ", runner.Logs, nil).Do(func(_, _, _, _ interface{}) {
+					events <- engine.Event{Action: engine.EventStart, ContainerID: "some-id"}
+					Eventually(logs).Should(gbytes.Say(`\[some-name\] % Error reading stats: some-error`))
+				}).Return(int64(0), nil),
+				mockContainer.EXPECT().Close(),
+			)
+			mockContainer.EXPECT().StatsContext(gomock.Any()).Return((<-chan engine.Stats)(statsIn))
+
+			Expect(runner.Run(config)).To(Equal(int64(0)))
+		})
+
+		It("should report droplet transfer progress", func() {
+			progress := make(chan engine.Progress, 10)
+			config := &RunConfig{
+				Droplet:          engine.NewStream(ioutil.NopCloser(bytes.NewBufferString("some-droplet")), 12),
+				Stack:            "some-stack",
+				OutputDir:        "/home/vcap",
+				TransferProgress: progress,
+				Color:            percentColor,
+				AppConfig:        &AppConfig{Name: "some-name"},
+				NetworkConfig: &NetworkConfig{
+					HostIP:   "some-ip",
+					HostPort: "400",
+				},
+			}
+			mockEngine.EXPECT().NewContainer(gomock.Any()).Return(mockContainer, nil)
+			mockEngine.EXPECT().EventsContext(gomock.Any()).Return(noEvents())
+			mockContainer.EXPECT().ID().Return("some-id").AnyTimes()
+
+			gomock.InOrder(
+				mockContainer.EXPECT().UploadTarToContext(gomock.Any(), gomock.Any(), "/home/vcap"),
+				mockContainer.EXPECT().StartContext(gomock.Any(), "[some-name] % ", runner.Logs, nil).Return(int64(0), nil),
+				mockContainer.EXPECT().Close(),
+			)
+
+			Expect(runner.Run(config)).To(Equal(int64(0)))
+			close(progress)
+			var last engine.Progress
+			for p := range progress {
+				Expect(p.ID).To(Equal("droplet"))
+				last = p
+			}
+			Expect(last.Message).To(Equal("Transferred"))
+			Expect(last.Current).To(Equal(int64(12)))
+			Expect(last.Total).To(Equal(int64(12)))
+		})
+
+		It("should remove the container without starting it when the droplet does not match its digest", func() {
+			config := &RunConfig{
+				Droplet:       engine.NewStream(ioutil.NopCloser(bytes.NewBufferString("some-droplet")), 12),
+				DropletDigest: "sha256:" + strings.Repeat("0", 64),
+				Stack:         "some-stack",
+				OutputDir:     "/home/vcap",
+				Color:         percentColor,
+				AppConfig:     &AppConfig{Name: "some-name"},
+				NetworkConfig: &NetworkConfig{
+					HostIP:   "some-ip",
+					HostPort: "400",
+				},
+			}
+			digestErr := &engine.DigestError{
+				Expected: "sha256:" + strings.Repeat("0", 64),
+				Actual:   digest.FromString("some-droplet").String(),
+			}
+			mockEngine.EXPECT().NewContainer(gomock.Any()).Return(mockContainer, nil)
+			gomock.InOrder(
+				mockContainer.EXPECT().UploadTarToContext(gomock.Any(), gomock.Any(), "/home/vcap"),
+				mockContainer.EXPECT().Close(),
+			)
+
+			_, err := runner.Run(config)
+			Expect(err).To(MatchError(digestErr))
+		})
+
+		It("should report when the app is killed for running out of memory", func() {
+			events := make(chan engine.Event, 2)
+			config := &RunConfig{
+				Droplet:   engine.NewStream(ioutil.NopCloser(bytes.NewBufferString("some-droplet")), 12),
+				Stack:     "some-stack",
+				OutputDir: "/home/vcap",
+				Color:     percentColor,
+				AppConfig: &AppConfig{Name: "some-name"},
+				NetworkConfig: &NetworkConfig{
+					HostIP:   "some-ip",
+					HostPort: "400",
+				},
+			}
+			mockEngine.EXPECT().NewContainer(gomock.Any()).Return(mockContainer, nil)
+			mockEngine.EXPECT().EventsContext(gomock.Any()).Return((<-chan engine.Event)(events))
+			mockContainer.EXPECT().ID().Return("some-id").AnyTimes()
+
+			gomock.InOrder(
+				mockContainer.EXPECT().UploadTarToContext(gomock.Any(), gomock.Any(), "/home/vcap"),
+				mockContainer.EXPECT().StartContext(gomock.Any(), "[some-name] % ", runner.Logs, nil).Do(func(_, _, _, _ interface{}) {
+					events <- engine.Event{Action: engine.EventOOM, ContainerID: "some-id"}
+					events <- engine.Event{Action: engine.EventDie, ContainerID: "some-id", ExitCode: 137}
+				}).Return(int64(137), nil),
+				mockContainer.EXPECT().Close(),
+			)
+
+			Expect(runner.Run(config)).To(Equal(int64(137)))
+			Expect(runner.Logs.(*bytes.Buffer).String()).To(Equal("some-logs[some-name] % App crashed: out of memory\n"))
+		})
+
+		It("should send the app URL each time the app starts", func() {
+			events := make(chan engine.Event, 1)
+			urls := make(chan string, 1)
+			config := &RunConfig{
+				Droplet:   engine.NewStream(ioutil.NopCloser(bytes.NewBufferString("some-droplet")), 12),
+				Stack:     "some-stack",
+				OutputDir: "/home/vcap",
+				URL:       urls,
+				Color:     percentColor,
+				AppConfig: &AppConfig{Name: "some-name"},
+				NetworkConfig: &NetworkConfig{
+					ContainerID:   "some-net-container",
+					ContainerPort: "8080",
+				},
+			}
+			mockEngine.EXPECT().NewContainer(gomock.Any()).Return(mockContainer, nil)
+			mockEngine.EXPECT().EventsContext(gomock.Any()).Return((<-chan engine.Event)(events))
+			mockContainer.EXPECT().ID().Return("some-id").AnyTimes()
+			mockContainer.EXPECT().NetworkContext(gomock.Any()).Return(&engine.NetworkInfo{
+				Ports: []engine.PortBinding{
+					{Protocol: "udp", ContainerPort: "8080", HostIP: "127.0.0.1", HostPort: "32767"},
+					{Protocol: "tcp", ContainerPort: "8080", HostIP: "0.0.0.0", HostPort: "32768"},
+				},
+			}, nil)
+
+			gomock.InOrder(
+				mockContainer.EXPECT().UploadTarToContext(gomock.Any(), gomock.Any(), "/home/vcap"),
+				mockContainer.EXPECT().StartContext(gomock.Any(), "[some-name] % ", runner.Logs, nil).Do(func(_, _, _, _ interface{}) {
+					events <- engine.Event{Action: engine.EventStart, ContainerID: "some-id"}
+					Eventually(urls).Should(Receive(Equal("http://localhost:32768")))
+				}).Return(int64(0), nil),
+				mockContainer.EXPECT().Close(),
+			)
+
+			Expect(runner.Run(config)).To(Equal(int64(0)))
+		})
+
 		// TODO: test without bind mounts, units, shell
 	})
 })
+
+func noEvents() <-chan engine.Event {
+	events := make(chan engine.Event)
+	close(events)
+	return events
+}
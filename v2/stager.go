@@ -1,9 +1,11 @@
 package v2
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"strconv"
 	"strings"
@@ -12,20 +14,30 @@ import (
 )
 
 type Stager struct {
-	Logs   io.Writer
-	engine Engine
+	Logs    io.Writer
+	LogSink engine.LogSink
+	engine  Engine
 }
 
 type StageConfig struct {
-	AppTar        io.Reader
-	Cache         ReadResetWriter
-	CacheEmpty    bool
-	BuildpackZips map[string]engine.Stream
-	Stack         string
-	OutputPath    string
-	ForceDetect   bool
-	Color         Colorizer
-	AppConfig     *AppConfig
+	AppTar           io.Reader
+	Cache            ReadResetWriter
+	CacheEmpty       bool
+	CacheDigest      string // optional, expected SHA-256 of Cache
+	BuildpackZips    map[string]engine.Stream
+	BuildpackDigests map[string]string // optional, expected SHA-256 of BuildpackZips by key
+	Stack            string
+	PullPolicy       PullPolicy             // default: if-not-present
+	PullProgress     chan<- engine.Progress // receives stack image pull progress
+	// TransferProgress receives buildpack, app, cache and droplet transfer progress.
+	// Droplet progress is sent after Stage returns, until the returned droplet is closed.
+	TransferProgress chan<- engine.Progress
+	OutputPath       string
+	MetadataPath     string           // optional, result JSON written by the builder
+	Metadata         *StagingMetadata // filled from MetadataPath
+	ForceDetect      bool
+	Color            Colorizer
+	AppConfig        *AppConfig
 }
 
 type ReadResetWriter interface {
@@ -41,10 +53,17 @@ func NewStager(engine Engine) *Stager {
 }
 
 func (s *Stager) Stage(config *StageConfig) (droplet engine.Stream, err error) {
+	return s.StageContext(context.Background(), config)
+}
+
+func (s *Stager) StageContext(ctx context.Context, config *StageConfig) (droplet engine.Stream, err error) {
 	containerConfig, err := s.buildConfig(config.AppConfig, config.Stack, config.ForceDetect)
 	if err != nil {
 		return engine.Stream{}, err
 	}
+	if err := pullImage(ctx, s.engine.NewImage(), config.Stack, config.PullPolicy, config.PullProgress); err != nil {
+		return engine.Stream{}, err
+	}
 	contr, err := s.engine.NewContainer(containerConfig)
 	if err != nil {
 		return engine.Stream{}, err
@@ -52,12 +71,16 @@ func (s *Stager) Stage(config *StageConfig) (droplet engine.Stream, err error) {
 	defer contr.CloseAfterStream(&droplet)
 
 	for checksum, zip := range config.BuildpackZips {
+		if digest, ok := config.BuildpackDigests[checksum]; ok {
+			zip = engine.NewDigestStream(zip, digest)
+		}
+		zip = engine.NewProgressStream(zip, "buildpack:"+checksum, config.TransferProgress)
 		if err := contr.StreamFileTo(zip, fmt.Sprintf("/buildpacks/%s.zip", checksum)); err != nil {
 			return engine.Stream{}, err
 		}
 	}
 
-	if err := contr.UploadTarTo(config.AppTar, "/tmp/app"); err != nil {
+	if err := upload(ctx, contr, config.AppTar, "/tmp/app", "", "app", config.TransferProgress); err != nil {
 		return engine.Stream{}, err
 	}
 
@@ -65,12 +88,12 @@ func (s *Stager) Stage(config *StageConfig) (droplet engine.Stream, err error) {
 		if err := contr.Mkdir("/tmp/cache"); err != nil {
 			return engine.Stream{}, err
 		}
-		if err := contr.UploadTarTo(config.Cache, "/tmp/cache"); err != nil {
+		if err := upload(ctx, contr, config.Cache, "/tmp/cache", config.CacheDigest, "cache", config.TransferProgress); err != nil {
 			return engine.Stream{}, err
 		}
 	}
 
-	status, err := contr.Start(config.Color("[%s] ", config.AppConfig.Name), s.Logs, nil)
+	status, err := contr.StartContext(ctx, config.Color("[%s] ", config.AppConfig.Name), s.Logs, nil)
 	if err != nil {
 		return engine.Stream{}, err
 	}
@@ -81,11 +104,20 @@ func (s *Stager) Stage(config *StageConfig) (droplet engine.Stream, err error) {
 	if err := config.Cache.Reset(); err != nil {
 		return engine.Stream{}, err
 	}
-	if err := streamOut(contr, config.Cache, "/cache/cache.tgz"); err != nil {
+	if err := streamOut(ctx, contr, config.Cache, "/cache/cache.tgz", "cache", config.TransferProgress); err != nil {
 		return engine.Stream{}, err
 	}
+	if config.MetadataPath != "" && config.Metadata != nil {
+		if err := readStagingMetadata(ctx, contr, config.MetadataPath, config.Metadata); err != nil {
+			return engine.Stream{}, err
+		}
+	}
 
-	return contr.StreamFileFrom(config.OutputPath)
+	droplet, err = contr.StreamFileFromContext(ctx, config.OutputPath)
+	if err != nil {
+		return engine.Stream{}, err
+	}
+	return engine.NewProgressStream(engine.NewDigestStream(droplet, ""), "droplet", config.TransferProgress), nil
 }
 
 func (s *Stager) buildConfig(app *AppConfig, stack string, forceDetect bool) (*engine.ContainerConfig, error) {
@@ -144,6 +176,8 @@ func (s *Stager) buildConfig(app *AppConfig, stack string, forceDetect bool) (*e
 
 	return &engine.ContainerConfig{
 		Name:       app.Name + "-staging",
+		AppName:    app.Name,
+		Role:       engine.RoleStaging,
 		Hostname:   app.Name,
 		Env:        mapToEnv(mergeMaps(env, app.StagingEnv, app.Env)),
 		Image:      stack,
@@ -152,13 +186,31 @@ func (s *Stager) buildConfig(app *AppConfig, stack string, forceDetect bool) (*e
 			"-skipDetect=" + strconv.FormatBool(!detect),
 			"-buildpackOrder", strings.Join(buildpacks, ","),
 		},
+		LogSink: s.LogSink,
 	}, nil
 }
 
-func streamOut(contr engine.Container, out io.Writer, path string) error {
-	stream, err := contr.StreamFileFrom(path)
+// upload verifies the digest of a tarball and reports its progress as it is uploaded.
+func upload(ctx context.Context, contr engine.Container, tarball io.Reader, path, digest, id string, progress chan<- engine.Progress) error {
+	if digest == "" && progress == nil {
+		return contr.UploadTarToContext(ctx, tarball, path)
+	}
+	stream := engine.NewStream(ioutil.NopCloser(tarball), -1)
+	if digest != "" {
+		stream = engine.NewDigestStream(stream, digest)
+	}
+	stream = engine.NewProgressStream(stream, id, progress)
+	if err := contr.UploadTarToContext(ctx, stream, path); err != nil {
+		stream.Close()
+		return err
+	}
+	return stream.Close()
+}
+
+func streamOut(ctx context.Context, contr engine.Container, out io.Writer, path, id string, progress chan<- engine.Progress) error {
+	stream, err := contr.StreamFileFromContext(ctx, path)
 	if err != nil {
 		return err
 	}
-	return stream.Out(out)
+	return engine.NewProgressStream(stream, id, progress).Out(out)
 }
@@ -2,6 +2,10 @@ package v2_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
 	"sort"
 
 	"github.com/golang/mock/gomock"
@@ -18,6 +22,7 @@ var _ = Describe("Stager", func() {
 		stager        *Stager
 		mockCtrl      *gomock.Controller
 		mockEngine    *mocks.MockEngine
+		mockImage     *mocks.MockImage
 		mockContainer *mocks.MockContainer
 		logs          *bytes.Buffer
 	)
@@ -25,7 +30,10 @@ var _ = Describe("Stager", func() {
 	BeforeEach(func() {
 		mockCtrl = gomock.NewController(GinkgoT())
 		mockEngine = mocks.NewMockEngine(mockCtrl)
+		mockImage = mocks.NewMockImage(mockCtrl)
 		mockContainer = mocks.NewMockContainer(mockCtrl)
+
+		mockEngine.EXPECT().NewImage().Return(mockImage).AnyTimes()
 		logs = bytes.NewBufferString("some logs\n")
 
 		stager = NewStager(mockEngine)
@@ -43,7 +51,8 @@ var _ = Describe("Stager", func() {
 			localCache := mocks.NewMockBuffer("some-old-cache")
 			remoteCache := mocks.NewMockBuffer("some-new-cache")
 			remoteCacheStream := engine.NewStream(remoteCache, int64(remoteCache.Len()))
-			dropletStream := engine.NewStream(mockReadCloser{Value: "some-droplet"}, 300)
+			var closedAfter *engine.Stream
+			dropletStream := engine.NewStream(ioutil.NopCloser(bytes.NewBufferString("some-droplet")), 12)
 
 			config := &StageConfig{
 				AppTar: bytes.NewBufferString("some-app-tar"),
@@ -80,8 +89,11 @@ var _ = Describe("Stager", func() {
 					},
 				},
 			}
+			mockImage.EXPECT().ExistsContext(gomock.Any(), "some-stack").Return(true, nil)
 			mockEngine.EXPECT().NewContainer(gomock.Any()).Do(func(config *engine.ContainerConfig) {
 				Expect(config.Name).To(Equal("some-name-staging"))
+				Expect(config.AppName).To(Equal("some-name"))
+				Expect(config.Role).To(Equal(engine.RoleStaging))
 				Expect(config.Hostname).To(Equal("some-name"))
 				sort.Strings(config.Env)
 				Expect(config.Env).To(Equal([]string{
@@ -97,24 +109,121 @@ var _ = Describe("Stager", func() {
 			}).Return(mockContainer, nil)
 
 			gomock.InOrder(
-				mockContainer.EXPECT().Start("[some-name] % ", logs, nil).Return(int64(0), nil).
+				mockContainer.EXPECT().StartContext(gomock.Any(), "[some-name] % ", logs, nil).Return(int64(0), nil).
 					After(mockContainer.EXPECT().StreamFileTo(buildpackZipStream1, "/buildpacks/some-checksum-one.zip")).
 					After(mockContainer.EXPECT().StreamFileTo(buildpackZipStream2, "/buildpacks/some-checksum-two.zip")).
-					After(mockContainer.EXPECT().UploadTarTo(config.AppTar, "/tmp/app")).
-					After(mockContainer.EXPECT().UploadTarTo(localCache, "/tmp/cache").
+					After(mockContainer.EXPECT().UploadTarToContext(gomock.Any(), config.AppTar, "/tmp/app")).
+					After(mockContainer.EXPECT().UploadTarToContext(gomock.Any(), localCache, "/tmp/cache").
 						After(mockContainer.EXPECT().Mkdir("/tmp/cache"))),
-				mockContainer.EXPECT().StreamFileFrom("/cache/cache.tgz").Return(remoteCacheStream, nil),
-				mockContainer.EXPECT().StreamFileFrom("/out/droplet.tgz").Return(dropletStream, nil),
-				mockContainer.EXPECT().CloseAfterStream(&dropletStream),
+				mockContainer.EXPECT().StreamFileFromContext(gomock.Any(), "/cache/cache.tgz").Return(remoteCacheStream, nil),
+				mockContainer.EXPECT().StreamFileFromContext(gomock.Any(), "/out/droplet.tgz").Return(dropletStream, nil),
+				mockContainer.EXPECT().CloseAfterStream(gomock.Any()).Do(func(stream *engine.Stream) {
+					closedAfter = stream
+				}),
 			)
 
-			Expect(stager.Stage(config)).To(Equal(dropletStream))
+			droplet, err := stager.Stage(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*closedAfter).To(Equal(droplet))
+			Expect(ioutil.ReadAll(droplet)).To(Equal([]byte("some-droplet")))
+			Expect(droplet.Digest()).To(Equal("sha256:ad975ce6d6b9028d73b820b34568871d48d43ba0d602e845b56d5ccbb48de2ce"))
 			Expect(localCache.Close()).To(Succeed())
 			Expect(localCache.Result()).To(Equal("some-new-cache"))
 			Expect(remoteCache.Result()).To(BeEmpty())
 			Expect(logs.String()).To(Equal("some logs\nBuildpacks: some-buildpack-one, some-buildpack-two\n"))
 		})
 
+		It("should read the buildpacks and start command from the staging result", func() {
+			localCache := mocks.NewMockBuffer("")
+			remoteCache := mocks.NewMockBuffer("some-new-cache")
+			result := mocks.NewMockBuffer(`{
+				"process_types": {"web": "some-start-command"},
+				"lifecycle_metadata": {
+					"buildpacks": [{"key": "some-checksum", "name": "some-buildpack", "version": "1.2.3"}]
+				}
+			}`)
+			dropletStream := engine.NewStream(ioutil.NopCloser(bytes.NewBufferString("some-droplet")), 12)
+			metadata := &StagingMetadata{}
+			config := &StageConfig{
+				AppTar:       bytes.NewBufferString("some-app-tar"),
+				Cache:        localCache,
+				CacheEmpty:   true,
+				Stack:        "some-stack",
+				OutputPath:   "/out/droplet.tgz",
+				MetadataPath: "/out/result.json",
+				Metadata:     metadata,
+				Color:        percentColor,
+				AppConfig:    &AppConfig{Name: "some-name"},
+			}
+			mockImage.EXPECT().ExistsContext(gomock.Any(), "some-stack").Return(true, nil)
+			mockEngine.EXPECT().NewContainer(gomock.Any()).Return(mockContainer, nil)
+			gomock.InOrder(
+				mockContainer.EXPECT().UploadTarToContext(gomock.Any(), config.AppTar, "/tmp/app"),
+				mockContainer.EXPECT().StartContext(gomock.Any(), "[some-name] % ", logs, nil).Return(int64(0), nil),
+				mockContainer.EXPECT().StreamFileFromContext(gomock.Any(), "/cache/cache.tgz").
+					Return(engine.NewStream(remoteCache, int64(remoteCache.Len())), nil),
+				mockContainer.EXPECT().StreamFileFromContext(gomock.Any(), "/out/result.json").
+					Return(engine.NewStream(result, int64(result.Len())), nil),
+				mockContainer.EXPECT().StreamFileFromContext(gomock.Any(), "/out/droplet.tgz").Return(dropletStream, nil),
+				mockContainer.EXPECT().CloseAfterStream(gomock.Any()),
+			)
+
+			droplet, err := stager.Stage(config)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ioutil.ReadAll(droplet)).To(Equal([]byte("some-droplet")))
+			Expect(metadata).To(Equal(&StagingMetadata{
+				Buildpacks:   []BuildpackMetadata{{Key: "some-checksum", Name: "some-buildpack", Version: "1.2.3"}},
+				StartCommand: "some-start-command",
+			}))
+		})
+
+		Context("when expected digests are provided", func() {
+			var config *StageConfig
+
+			BeforeEach(func() {
+				config = &StageConfig{
+					AppTar:     bytes.NewBufferString("some-app-tar"),
+					Cache:      mocks.NewMockBuffer("some-old-cache"),
+					Stack:      "some-stack",
+					OutputPath: "/out/droplet.tgz",
+					Color:      percentColor,
+					AppConfig:  &AppConfig{Name: "some-name"},
+				}
+				mockImage.EXPECT().ExistsContext(gomock.Any(), "some-stack").Return(true, nil)
+				mockEngine.EXPECT().NewContainer(gomock.Any()).Return(mockContainer, nil)
+				mockContainer.EXPECT().CloseAfterStream(gomock.Any())
+			})
+
+			It("should verify buildpack zips as they are uploaded", func() {
+				config.CacheEmpty = true
+				config.BuildpackZips = map[string]engine.Stream{
+					"some-checksum": engine.NewStream(ioutil.NopCloser(bytes.NewBufferString("some-buildpack-zip")), 18),
+				}
+				config.BuildpackDigests = map[string]string{"some-checksum": "sha256:some-other-digest"}
+				mockContainer.EXPECT().StreamFileTo(gomock.Any(), "/buildpacks/some-checksum.zip").Do(func(stream engine.Stream, _ string) {
+					Expect(ioutil.ReadAll(stream)).To(Equal([]byte("some-buildpack-zip")))
+					Expect(stream.Close()).To(MatchError(HavePrefix("digest mismatch: expected sha256:some-other-digest, got sha256:")))
+				}).Return(errors.New("some-error"))
+
+				_, err := stager.Stage(config)
+				Expect(err).To(MatchError("some-error"))
+			})
+
+			It("should return an error when the cache does not match its digest", func() {
+				config.CacheDigest = "sha256:some-other-digest"
+				gomock.InOrder(
+					mockContainer.EXPECT().UploadTarToContext(gomock.Any(), config.AppTar, "/tmp/app"),
+					mockContainer.EXPECT().Mkdir("/tmp/cache"),
+					mockContainer.EXPECT().UploadTarToContext(gomock.Any(), gomock.Any(), "/tmp/cache").Do(func(_ context.Context, cache io.Reader, _ string) {
+						Expect(ioutil.ReadAll(cache)).To(Equal([]byte("some-old-cache")))
+					}),
+				)
+
+				_, err := stager.Stage(config)
+				Expect(err).To(BeAssignableToTypeOf(&engine.DigestError{}))
+			})
+		})
+
 		// TODO: test unavailable buildpack versions
 		// TODO: test empty cache
 		// TODO: test single-buildpack case, detection, force detection
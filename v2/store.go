@@ -0,0 +1,226 @@
+package v2
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	godigest "github.com/opencontainers/go-digest"
+
+	"github.com/buildpack/forge/engine"
+)
+
+// DropletStore keeps droplets on local disk by SHA-256 digest:
+//
+//	<dir>/<hex>.droplet  the droplet as returned by the stager
+//	<dir>/<hex>.json     its DropletInfo
+type DropletStore struct {
+	dir string
+}
+
+type DropletInfo struct {
+	Digest       string              `json:"digest"`
+	Size         int64               `json:"size"`
+	App          string              `json:"app"`
+	Stack        string              `json:"stack"`
+	Buildpacks   []BuildpackMetadata `json:"buildpacks"`
+	StartCommand string              `json:"start_command,omitempty"`
+	Created      time.Time           `json:"created"`
+}
+
+type DropletNotFoundError struct {
+	Digest string
+}
+
+func (e *DropletNotFoundError) Error() string {
+	return fmt.Sprintf("droplet %s not found", e.Digest)
+}
+
+func NewDropletStore(dir string) *DropletStore {
+	return &DropletStore{dir: dir}
+}
+
+// Save reads and closes a droplet, then stores it with info.
+// The digest and size of info are set by Save, and the creation time defaults to now.
+func (s *DropletStore) Save(droplet engine.Stream, info DropletInfo) (*DropletInfo, error) {
+	defer droplet.Close()
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, err
+	}
+	tmp, err := ioutil.TempFile(s.dir, ".tmp-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	digester := godigest.Canonical.Digester()
+	size, err := io.Copy(io.MultiWriter(tmp, digester.Hash()), droplet)
+	if err != nil {
+		return nil, err
+	}
+	if err := droplet.Close(); err != nil {
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	info.Digest = digester.Digest().String()
+	info.Size = size
+	if info.Created.IsZero() {
+		info.Created = time.Now().UTC()
+	}
+	if err := os.Rename(tmp.Name(), s.path(info.Digest, ".droplet")); err != nil {
+		return nil, err
+	}
+	if err := s.writeInfo(&info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Open returns a stored droplet that fails with a *engine.DigestError when
+// it is closed after being read if it was modified on disk.
+func (s *DropletStore) Open(digest string) (engine.Stream, error) {
+	digest, err := normalizeDigest(digest)
+	if err != nil {
+		return engine.Stream{}, err
+	}
+	file, err := os.Open(s.path(digest, ".droplet"))
+	if os.IsNotExist(err) {
+		return engine.Stream{}, &DropletNotFoundError{Digest: digest}
+	}
+	if err != nil {
+		return engine.Stream{}, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return engine.Stream{}, err
+	}
+	return engine.NewDigestStream(engine.NewStream(file, stat.Size()), digest), nil
+}
+
+func (s *DropletStore) Info(digest string) (*DropletInfo, error) {
+	digest, err := normalizeDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(s.path(digest, ".json"))
+	if os.IsNotExist(err) {
+		return nil, &DropletNotFoundError{Digest: digest}
+	}
+	if err != nil {
+		return nil, err
+	}
+	var info DropletInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("invalid droplet info for %s: %s", digest, err)
+	}
+	return &info, nil
+}
+
+// List returns the stored droplets, newest first.
+func (s *DropletStore) List() ([]*DropletInfo, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var droplets []*DropletInfo
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		info, err := s.Info(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			return nil, err
+		}
+		droplets = append(droplets, info)
+	}
+	sort.SliceStable(droplets, func(i, j int) bool {
+		return droplets[i].Created.After(droplets[j].Created)
+	})
+	return droplets, nil
+}
+
+func (s *DropletStore) Delete(digest string) error {
+	digest, err := normalizeDigest(digest)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(s.path(digest, ".json")); os.IsNotExist(err) {
+		return &DropletNotFoundError{Digest: digest}
+	} else if err != nil {
+		return err
+	}
+	if err := os.Remove(s.path(digest, ".droplet")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Prune deletes all but the keep newest droplets of each app and returns the deleted droplets.
+func (s *DropletStore) Prune(keep int) (pruned []*DropletInfo, err error) {
+	droplets, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	kept := map[string]int{}
+	for _, info := range droplets {
+		if kept[info.App] < keep {
+			kept[info.App]++
+			continue
+		}
+		if err := s.Delete(info.Digest); err != nil {
+			return pruned, err
+		}
+		pruned = append(pruned, info)
+	}
+	return pruned, nil
+}
+
+func (s *DropletStore) writeInfo(info *DropletInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(s.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(info.Digest, ".json"))
+}
+
+func (s *DropletStore) path(digest, ext string) string {
+	return filepath.Join(s.dir, godigest.Digest(digest).Hex()+ext)
+}
+
+// normalizeDigest accepts a SHA-256 digest with or without the sha256: prefix.
+func normalizeDigest(digest string) (string, error) {
+	if !strings.HasPrefix(digest, "sha256:") {
+		digest = "sha256:" + digest
+	}
+	digest = strings.ToLower(digest)
+	if err := godigest.Digest(digest).Validate(); err != nil {
+		return "", fmt.Errorf("invalid droplet digest %s: %s", digest, err)
+	}
+	return digest, nil
+}
@@ -0,0 +1,136 @@
+package v2_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/buildpack/forge/engine"
+	. "github.com/buildpack/forge/v2"
+)
+
+var _ = Describe("DropletStore", func() {
+	var (
+		store  *DropletStore
+		tmpDir string
+	)
+
+	newDroplet := func(data string) engine.Stream {
+		return engine.NewStream(ioutil.NopCloser(bytes.NewBufferString(data)), int64(len(data)))
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "forge-store-test")
+		Expect(err).NotTo(HaveOccurred())
+		store = NewDropletStore(filepath.Join(tmpDir, "droplets"))
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmpDir)).To(Succeed())
+	})
+
+	Describe("#Save", func() {
+		It("should store the droplet and its info by digest", func() {
+			info, err := store.Save(newDroplet("some-droplet"), DropletInfo{
+				App:        "some-app",
+				Stack:      "some-stack",
+				Buildpacks: []BuildpackMetadata{{Key: "some-checksum", Name: "some-buildpack", Version: "1.2.3"}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Digest).To(Equal(digest.FromString("some-droplet").String()))
+			Expect(info.Size).To(Equal(int64(12)))
+			Expect(info.Created).NotTo(BeZero())
+
+			stored, err := store.Info(info.Digest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stored.App).To(Equal("some-app"))
+			Expect(stored.Stack).To(Equal("some-stack"))
+			Expect(stored.Buildpacks).To(Equal(info.Buildpacks))
+			Expect(stored.Created.Equal(info.Created)).To(BeTrue())
+
+			droplet, err := store.Open(info.Digest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(droplet.Size).To(Equal(int64(12)))
+			out := &bytes.Buffer{}
+			Expect(droplet.Out(out)).To(Succeed())
+			Expect(out.String()).To(Equal("some-droplet"))
+		})
+
+		It("should return an error when the droplet fails verification", func() {
+			droplet := engine.NewDigestStream(newDroplet("some-droplet"), "sha256:"+strings.Repeat("0", 64))
+			_, err := store.Save(droplet, DropletInfo{App: "some-app"})
+			Expect(err).To(BeAssignableToTypeOf(&engine.DigestError{}))
+			Expect(store.List()).To(BeEmpty())
+		})
+	})
+
+	Describe("#Open", func() {
+		It("should return a droplet that fails verification when modified on disk", func() {
+			info, err := store.Save(newDroplet("some-droplet"), DropletInfo{App: "some-app"})
+			Expect(err).NotTo(HaveOccurred())
+			path := filepath.Join(tmpDir, "droplets", strings.TrimPrefix(info.Digest, "sha256:")+".droplet")
+			Expect(ioutil.WriteFile(path, []byte("some-droplex"), 0644)).To(Succeed())
+
+			droplet, err := store.Open(strings.TrimPrefix(info.Digest, "sha256:"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(droplet.Out(ioutil.Discard)).To(BeAssignableToTypeOf(&engine.DigestError{}))
+		})
+
+		It("should return a DropletNotFoundError when the droplet is missing", func() {
+			_, err := store.Open(digest.FromString("some-droplet").String())
+			Expect(err).To(BeAssignableToTypeOf(&DropletNotFoundError{}))
+		})
+
+		It("should return an error when the digest is invalid", func() {
+			_, err := store.Open("../some-file")
+			Expect(err).To(MatchError(HavePrefix("invalid droplet digest sha256:../some-file")))
+		})
+	})
+
+	Describe("#List / #Delete / #Prune", func() {
+		It("should list droplets newest first and prune all but the newest of each app", func() {
+			var digests []string
+			created := time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+			for i, app := range []string{"some-app", "some-other-app", "some-app", "some-app"} {
+				info, err := store.Save(newDroplet(fmt.Sprintf("some-droplet-%d", i)), DropletInfo{
+					App:     app,
+					Created: created.Add(time.Duration(i) * time.Hour),
+				})
+				Expect(err).NotTo(HaveOccurred())
+				digests = append(digests, info.Digest)
+			}
+			droplets, err := store.List()
+			Expect(err).NotTo(HaveOccurred())
+			var listed []string
+			for _, info := range droplets {
+				listed = append(listed, info.Digest)
+			}
+			Expect(listed).To(Equal([]string{digests[3], digests[2], digests[1], digests[0]}))
+
+			pruned, err := store.Prune(1)
+			Expect(err).NotTo(HaveOccurred())
+			var prunedDigests []string
+			for _, info := range pruned {
+				prunedDigests = append(prunedDigests, info.Digest)
+			}
+			Expect(prunedDigests).To(ConsistOf(digests[0], digests[2]))
+
+			Expect(store.Delete(digests[1])).To(Succeed())
+			Expect(store.Delete(digests[1])).To(BeAssignableToTypeOf(&DropletNotFoundError{}))
+			droplets, err = store.List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(droplets).To(HaveLen(1))
+			Expect(droplets[0].Digest).To(Equal(digests[3]))
+			Expect(droplets[0].Created).To(Equal(created.Add(3 * time.Hour)))
+		})
+	})
+})